@@ -2,21 +2,214 @@ package administration
 
 import (
     "encoding/json"
+    "errors"
     homedir "github.com/mitchellh/go-homedir"
     "io/ioutil"
+    "net"
     "os"
+    "strings"
+    "time"
 )
 
 const (
     fileDirectory = `.config/kodiremote/`
     filePath = fileDirectory + `kodiremote.conf`
+    statePath = fileDirectory + `state.json`
+    // DefaultPort is the port of Kodi's web server if it was not changed.
+    DefaultPort = `8080`
+    // DefaultWebSocketPort is the port of Kodi's WebSocket server if it was not changed.
+    DefaultWebSocketPort = `9090`
+    // DefaultMaxAttempts is used by --repeat-until-success if no maximum is given.
+    DefaultMaxAttempts = 5
+    // DefaultConnectTimeout is used if no connect timeout is configured.
+    DefaultConnectTimeout = 5 * time.Second
+    // ConfigVersion is the version of the format of the configuration file.
+    // It needs to be increased together with a new migration.
+    ConfigVersion = 1
+    // AddressVariable is the environment variable containing the address
+    // of Kodi as host:port.
+    AddressVariable = `KODI_ADDR`
 )
 var fullPathCache string = ``
 
+// migrations upgrade the configuration from the version of their index
+// to the next version.
+var migrations = []func(configuration *Configuration) {
+    migrateToVersion1,
+}
+
 // Configuration represents all configurable options inside this tool.
 type Configuration struct {
+    // Version is the version of the format the configuration was saved in.
+    Version int
     Host string
     Port string    
+    // Profiles maps names to several Kodi installations, e.g. "living".
+    Profiles map[string]Profile `json:",omitempty"`
+    // DefaultProfile is the profile used if none is passed on the command
+    // line. If it is empty Host and Port are used.
+    DefaultProfile string `json:",omitempty"`
+    // ActiveProfile is the name of the profile in use. It is never saved.
+    ActiveProfile string `json:"-"`
+    // LogFile is the path of the file every sent request is appended to.
+    LogFile string
+    // ConnectTimeout limits the time to connect to Kodi, e.g. "5s".
+    ConnectTimeout string
+    // ReadTimeout limits the time of a whole request, e.g. "10m".
+    // An empty value means no limit.
+    ReadTimeout string
+    // WebSocketPort is the port of Kodi's WebSocket server
+    // which sends notifications.
+    WebSocketPort string
+    // OffMutes makes the off command mute the audio after stopping
+    // all players.
+    OffMutes bool
+    // PlayPauseAction sends play and pause as the action "playpause"
+    // instead of Player.PlayPause for players which ignore the method.
+    PlayPauseAction bool
+    // VolumeOSDSetting is the id of the boolean setting which shows the
+    // volume bar. --silent-volume turns it off while changing the volume.
+    VolumeOSDSetting string
+    // KodiVersion is the version of Kodi's JSON-RPC API, e.g. "12.4.0".
+    // It is detected once and used to adapt params which changed.
+    KodiVersion string
+    // RawParams replaces the params created by the command. It is only
+    // set by the command line and never saved.
+    RawParams map[string]interface{} `json:"-"`
+    // OutputTemplate is a Go template which formats the output of
+    // status commands. It is only set by the command line.
+    OutputTemplate string `json:"-"`
+    // OutputFormat is the format listings are printed in, e.g. "table".
+    // It is only set by the command line.
+    OutputFormat string `json:"-"`
+    // Quiet suppresses progress indicators. It is only set by the command line.
+    Quiet bool `json:"-"`
+    // MaxAttempts is the number of times a request is sent until it
+    // succeeds. It is only set by the command line.
+    MaxAttempts int `json:"-"`
+    // Color is "always" or "never" to force or disable colored output,
+    // otherwise it is colored on terminals. It is only set by the command line.
+    Color string `json:"-"`
+    // Expectations are checks like "speed=0" of the response of a
+    // command. It is only set by the command line.
+    Expectations []string `json:"-"`
+    // NoConfigWrite prevents saving the configuration and the state.
+    // It is only set by the command line.
+    NoConfigWrite bool `json:"-"`
+    // AssumeYes skips the confirmation of destructive commands. It is
+    // only set by the command line.
+    AssumeYes bool `json:"-"`
+}
+
+// Profile is a Kodi installation saved under a name.
+type Profile struct {
+    // Address is the address of Kodi like "kodi:8080".
+    Address string
+    // KodiVersion is the version of the JSON-RPC API of this installation.
+    // It is detected once like the version of the configuration.
+    KodiVersion string `json:",omitempty"`
+}
+
+// State contains what is remembered between calls to return
+// to it later. It is saved separately from the configuration.
+type State struct {
+    // Window are the params of the window which was activated last.
+    Window map[string]interface{} `json:"window,omitempty"`
+    // Item is the item which was opened last.
+    Item map[string]interface{} `json:"item,omitempty"`
+}
+
+// GetWebSocketPort returns the configured WebSocket port
+// or the default one.
+func (self Configuration) GetWebSocketPort() string {
+    if len(self.WebSocketPort) == 0 {
+        return DefaultWebSocketPort
+    }
+    return self.WebSocketPort
+}
+
+// SetAddress sets host and port from an address like "kodi:8080". The port
+// defaults to DefaultPort, IPv6 hosts need brackets if a port is given,
+// e.g. "[::1]:8080".
+func (self *Configuration) SetAddress(address string) error {
+    host, port := address, DefaultPort
+    if strings.HasPrefix(address, `[`) && !strings.HasSuffix(address, `]`) || strings.Count(address, `:`) == 1 {
+        var err error
+        if host, port, err = net.SplitHostPort(address); err != nil {
+            return errors.New(`Illegal address ` + address + `, use e.g. "kodi:8080" or "[::1]:8080".`)
+        }
+    }
+    host = strings.Trim(host, `[]`)
+    if len(host) == 0 || len(port) == 0 {
+        return errors.New(`Illegal address ` + address + `, use e.g. "kodi:8080" or "[::1]:8080".`)
+    }
+    self.Host = host
+    self.Port = port
+    return nil
+}
+
+// AddProfile saves the address like "kodi:8080" under the name.
+func (self *Configuration) AddProfile(name, address string) error {
+    if len(name) == 0 {
+        return errors.New(`The profile needs a name, use e.g. "living=kodi:8080".`)
+    }
+    var check Configuration
+    if err := check.SetAddress(address); err != nil {
+        return err
+    }
+    if self.Profiles == nil {
+        self.Profiles = map[string]Profile{}
+    }
+    self.Profiles[name] = Profile{Address: address}
+    return nil
+}
+
+// UseProfile sets host, port and the version of the JSON-RPC API
+// to the ones saved in the profile.
+func (self *Configuration) UseProfile(name string) error {
+    profile, success := self.Profiles[name]
+    if !success {
+        return errors.New(`Unknown profile ` + name + `, add it with --add-profile=` + name + `=<host>:<port>.`)
+    }
+    self.ActiveProfile = name
+    self.KodiVersion = profile.KodiVersion
+    return self.SetAddress(profile.Address)
+}
+
+// SetProfileKodiVersion changes the version of the JSON-RPC API
+// saved in the profile.
+func (self *Configuration) SetProfileKodiVersion(name, version string) error {
+    profile, success := self.Profiles[name]
+    if !success {
+        return errors.New(`Unknown profile ` + name + `, add it with --add-profile=` + name + `=<host>:<port>.`)
+    }
+    profile.KodiVersion = version
+    self.Profiles[name] = profile
+    return nil
+}
+
+// GetConnectTimeout returns the time allowed to connect to Kodi.
+func (self Configuration) GetConnectTimeout() (time.Duration, error) {
+    return parseTimeout(self.ConnectTimeout, DefaultConnectTimeout)
+}
+
+// GetReadTimeout returns the time allowed for a whole request,
+// zero means no limit.
+func (self Configuration) GetReadTimeout() (time.Duration, error) {
+    return parseTimeout(self.ReadTimeout, 0)
+}
+
+// parseTimeout parses a timeout like "5s" and returns defaultTimeout
+// if the timeout is empty.
+func parseTimeout(timeout string, defaultTimeout time.Duration) (time.Duration, error) {
+    if len(timeout) == 0 {
+        return defaultTimeout, nil
+    }
+    duration, err := time.ParseDuration(timeout)
+    if err != nil || duration < 0 {
+        return 0, errors.New(`Illegal timeout ` + timeout + `, use e.g. "5s" or "10m".`)
+    }
+    return duration, nil
 }
 
 func getFullConfigPath() (string, error) {
@@ -31,20 +224,57 @@ func getFullConfigPath() (string, error) {
     return fullPathCache, nil
 }
 
-func loadConfiguration() (Configuration, error) {
+// loadConfiguration reads the configuration file and migrates it to the
+// current version. The second return value is true if it was migrated
+// and needs to be saved.
+func loadConfiguration() (Configuration, bool, error) {
     var configuration Configuration
     path, err := getFullConfigPath()
     
     if err == nil {
         if jsonString, err := ioutil.ReadFile(path); err == nil {
             if err = json.Unmarshal([]byte(jsonString), &configuration); err == nil {
-                return configuration, nil
+                migrated := migrateConfiguration(&configuration)
+                applyDefaults(&configuration)
+                return configuration, migrated, nil
             }
         } else {
-            return configuration, err
+            return configuration, false, err
         }
     }
-    return configuration, err
+    return configuration, false, err
+}
+
+// migrateConfiguration applies all migrations the configuration is missing
+// and returns true if any was applied.
+func migrateConfiguration(configuration *Configuration) bool {
+    migrated := false
+    for configuration.Version < ConfigVersion {
+        migrations[configuration.Version](configuration)
+        configuration.Version++
+        migrated = true
+    }
+    return migrated
+}
+
+// applyDefaults fills the options which need a value on every load,
+// e.g. the port after it was cleared with --port=.
+func applyDefaults(configuration *Configuration) {
+    if len(configuration.Host) > 0 && len(configuration.Port) == 0 {
+        configuration.Port = DefaultPort
+    }
+}
+
+// migrateToVersion1 fills the options which were added
+// before the configuration had a version. The port is filled
+// by applyDefaults.
+func migrateToVersion1(configuration *Configuration) {
+    if len(configuration.WebSocketPort) == 0 {
+        configuration.WebSocketPort = DefaultWebSocketPort
+    }
+    if len(configuration.ConnectTimeout) == 0 {
+        configuration.ConnectTimeout = DefaultConnectTimeout.String()
+    }
 }
 
 // WriteConfiguration writes the configuration to the filesystem.
@@ -59,24 +289,91 @@ func WriteConfiguration(configuration Configuration) error {
     return err
 }
 
+// SaveKodiVersion saves the version of the JSON-RPC API of the configuration.
+// If a profile is active the version is saved in the profile and the address
+// of the profile is not saved as host and port.
+func SaveKodiVersion(configuration Configuration) error {
+    if len(configuration.ActiveProfile) == 0 {
+        return WriteConfiguration(configuration)
+    }
+    saved := ReadConfiguration()
+    if err := saved.SetProfileKodiVersion(configuration.ActiveProfile, configuration.KodiVersion); err != nil {
+        return err
+    }
+    return WriteConfiguration(saved)
+}
+
+// ReadState loads the saved state. If none was saved yet an empty
+// state is returned.
+func ReadState() (State, error) {
+    var state State
+    home, err := homedir.Dir()
+    if err != nil {
+        return state, err
+    }
+    jsonState, err := ioutil.ReadFile(home + `/` + statePath)
+    if os.IsNotExist(err) {
+        return state, nil
+    } else if err != nil {
+        return state, err
+    }
+    err = json.Unmarshal(jsonState, &state)
+    return state, err
+}
+
+// WriteState saves the state to the filesystem.
+func WriteState(state State) error {
+    jsonState, err := json.Marshal(state)
+    if err != nil {
+        return err
+    }
+    home, err := homedir.Dir()
+    if err != nil {
+        return err
+    }
+    os.MkdirAll(home + `/` + fileDirectory, os.ModeDir | 0700)
+    return ioutil.WriteFile(home + `/` + statePath, jsonState, 0600)
+}
+
 // CreateConfiguration checks if an configuration exists and if there
 // exists one it is loaded and returned, otherwise an empty configuration
-// will be created, saved and returned.
+// will be created, saved and returned. A configuration of an older version
+// is migrated and saved once.
 func CreateConfiguration() (Configuration, error) {
     homedir.DisableCache = false
     
-    if configuration, err := loadConfiguration(); err != nil {
+    if configuration, migrated, err := loadConfiguration(); err != nil {
         if home, err := homedir.Dir(); err == nil {
-            var initialConfig Configuration
-            initialConfig.Port = `80`
+            initialConfig := createInitialConfiguration()
             os.MkdirAll(home + `/` + fileDirectory, os.ModeDir | 0700)
             err = WriteConfiguration(initialConfig)
             return initialConfig, err
         } else {
             return configuration, err
         }
+    } else if migrated {
+        return configuration, WriteConfiguration(configuration)
     } else {
         return configuration, nil
     }
 }
 
+// ReadConfiguration loads the configuration if one exists, otherwise the
+// initial configuration is returned. Nothing is written to the filesystem.
+func ReadConfiguration() Configuration {
+    homedir.DisableCache = false
+    
+    if configuration, _, err := loadConfiguration(); err == nil {
+        return configuration
+    }
+    return createInitialConfiguration()
+}
+
+// createInitialConfiguration creates the configuration used
+// if none exists yet.
+func createInitialConfiguration() Configuration {
+    var initialConfig Configuration
+    initialConfig.Version = ConfigVersion
+    initialConfig.Port = DefaultPort
+    return initialConfig
+}
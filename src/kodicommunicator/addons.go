@@ -0,0 +1,51 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "strings"
+)
+
+// allAddonTypes makes Addons.GetAddons return the addons of every type.
+const allAddonTypes = `unknown`
+
+// Addon is an installed addon returned by Addons.GetAddons.
+type Addon struct {
+    AddonID string `json:"addonid"`
+    Type string `json:"type"`
+    Name string `json:"name"`
+}
+
+// getAddons lists the installed addons of addonType,
+// e.g. "xbmc.python.pluginsource", or all of them if it is empty.
+func getAddons(config administration.Configuration, addonType string) (string, error) {
+    if len(addonType) == 0 {
+        addonType = allAddonTypes
+    }
+    var addons struct {
+        Addons []Addon `json:"addons"`
+    }
+    result, err := callMethod(config, `Addons.GetAddons`, map[string]interface{} {
+        `type`: addonType,
+        `enabled`: `all`,
+        `properties`: []string{`name`},
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &addons)
+    }
+    if err != nil {
+        return ``, err
+    }
+    plain := `No addons found.`
+    lines := make([]string, len(addons.Addons))
+    rows := make([][]string, len(addons.Addons))
+    for i, addon := range addons.Addons {
+        lines[i] = addon.AddonID + ` - ` + addon.Name
+        rows[i] = []string{addon.AddonID, addon.Name, addon.Type}
+    }
+    if len(lines) > 0 {
+        plain = strings.Join(lines, "\n")
+    }
+    return formatListing(config, addons.Addons, []string{`ID`, `Name`, `Type`}, rows, plain)
+}
@@ -0,0 +1,46 @@
+package kodicommunicator
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// appProperties are the properties requested by appinfo.
+var appProperties = []string{`name`, `version`}
+
+// AppInfo is the build of Kodi returned by Application.GetProperties.
+type AppInfo struct {
+    Name string `json:"name"`
+    Version struct {
+        Major int `json:"major"`
+        Minor int `json:"minor"`
+        // Revision is a string like "20230315-4a3b5c" in newer
+        // versions and a number in older ones.
+        Revision interface{} `json:"revision"`
+        Tag string `json:"tag"`
+        TagVersion string `json:"tagversion"`
+    } `json:"version"`
+}
+
+// formatAppInfo displays the name of the build and its version,
+// one property per line.
+func formatAppInfo(result json.RawMessage) (string, error) {
+    var info AppInfo
+    if err := json.Unmarshal(result, &info); err != nil {
+        return ``, err
+    }
+    version := strconv.Itoa(info.Version.Major) + `.` + strconv.Itoa(info.Version.Minor)
+    if len(info.Version.Tag) > 0 && info.Version.Tag != `stable` {
+        version += ` ` + info.Version.Tag + info.Version.TagVersion
+    }
+    lines := []string{
+        `Name: ` + info.Name,
+        `Version: ` + version,
+    }
+    if info.Version.Revision != nil {
+        lines = append(lines, `Revision: ` + fmt.Sprint(info.Version.Revision))
+    }
+    return strings.Join(lines, "\n"), nil
+}
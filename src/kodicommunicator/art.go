@@ -0,0 +1,86 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "errors"
+    "net/url"
+    "strings"
+)
+
+// imagePrefix starts the paths of images in Kodi's texture cache.
+const imagePrefix = `image://`
+
+// artTypes are the images of the current item which can be resolved.
+var artTypes = []string{`thumbnail`, `fanart`}
+
+// getCurrentArtURL returns the URL of the image of the current item, e.g. its
+// thumbnail, which can be downloaded from Kodi's web server.
+func getCurrentArtURL(config administration.Configuration, artType string) (string, error) {
+    playerID, err := getActivePlayerID(config)
+    if err != nil {
+        return ``, err
+    }
+    var item struct {
+        Item map[string]interface{} `json:"item"`
+    }
+    result, err := callMethod(config, `Player.GetItem`, map[string]interface{} {
+        `playerid`: playerID,
+        `properties`: []string{artType},
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &item)
+    }
+    if err != nil {
+        return ``, err
+    }
+    path, _ := item.Item[artType].(string)
+    if len(path) == 0 {
+        return ``, errors.New(`The current item has no ` + artType + `.`)
+    }
+    if decodedPath := DecodeImagePath(path); strings.HasPrefix(decodedPath, `http://`) || strings.HasPrefix(decodedPath, `https://`) {
+        // images of online sources like scrapers can be downloaded directly
+        return decodedPath, nil
+    }
+    return prepareDownload(config, path)
+}
+
+// DecodeImagePath turns an image path returned by Kodi like
+// "image://%2fmusic%2fcover.jpg/" into the path or URL of the original
+// image, e.g. "/music/cover.jpg". Other paths are returned unchanged.
+func DecodeImagePath(path string) string {
+    if !strings.HasPrefix(path, imagePrefix) {
+        return path
+    }
+    encoded := strings.TrimSuffix(strings.TrimPrefix(path, imagePrefix), `/`)
+    decoded, err := url.PathUnescape(encoded)
+    if err != nil {
+        return path
+    }
+    return decoded
+}
+
+// prepareDownload asks Kodi for the path a file, e.g. an image like
+// "image://...", can be downloaded from and returns its complete URL.
+func prepareDownload(config administration.Configuration, path string) (string, error) {
+    var download struct {
+        Details struct {
+            Path string `json:"path"`
+        } `json:"details"`
+        Protocol string `json:"protocol"`
+    }
+    result, err := callMethod(config, `Files.PrepareDownload`, map[string]interface{} {
+        `path`: path,
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &download)
+    }
+    if err != nil {
+        return ``, err
+    }
+    if download.Protocol != `http` {
+        return ``, errors.New(`Kodi does not offer ` + path + ` for download.`)
+    }
+    return `http://` + createAddress(config) + `/` + download.Details.Path, nil
+}
@@ -0,0 +1,55 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "os"
+)
+
+const (
+    // ColorAuto colors the output if stdout is a terminal and
+    // NO_COLOR is not set, it is the default.
+    ColorAuto = `auto`
+    // ColorAlways colors the output even if it is no terminal.
+    ColorAlways = `always`
+    // ColorNever never colors the output.
+    ColorNever = `never`
+    // noColorVariable disables colors if it is set to any value,
+    // see https://no-color.org.
+    noColorVariable = `NO_COLOR`
+    colorReset = "\x1b[0m"
+    colorBold = "\x1b[1m"
+    colorGreen = "\x1b[32m"
+    colorYellow = "\x1b[33m"
+)
+
+// ColorModes are the values --color accepts.
+var ColorModes = []string{ColorAuto, ColorAlways, ColorNever}
+
+// useColor returns true if the output should be colored.
+func useColor(config administration.Configuration) bool {
+    switch config.Color {
+    case ColorAlways:
+        return true
+    case ColorNever:
+        return false
+    }
+    return len(os.Getenv(noColorVariable)) == 0 && isTerminal(os.Stdout)
+}
+
+// colorize wraps text in the ANSI escape codes of color
+// if the output should be colored.
+func colorize(config administration.Configuration, text, color string) string {
+    if len(text) == 0 || !useColor(config) {
+        return text
+    }
+    return color + text + colorReset
+}
+
+// playbackColor returns green while playing and yellow while paused.
+func playbackColor(nowPlaying NowPlaying) string {
+    if nowPlaying.Playing {
+        return colorGreen
+    }
+    return colorYellow
+}
@@ -0,0 +1,60 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "strconv"
+    "strings"
+)
+
+// seekObjectVersion is the first major JSON-RPC API version which expects
+// the value of Player.Seek as an object like {"step":"smallforward"}.
+const seekObjectVersion = 10
+
+// DetectKodiVersion asks Kodi for the version of its JSON-RPC API,
+// e.g. "12.4.0".
+func DetectKodiVersion(config administration.Configuration) (string, error) {
+    var version struct {
+        Version struct {
+            Major int `json:"major"`
+            Minor int `json:"minor"`
+            Patch int `json:"patch"`
+        } `json:"version"`
+    }
+    result, err := callMethod(config, `JSONRPC.Version`, nil)
+    if err == nil {
+        err = json.Unmarshal(result, &version)
+    }
+    if err != nil {
+        return ``, err
+    }
+    return strconv.Itoa(version.Version.Major) + `.` + strconv.Itoa(version.Version.Minor) + `.` + strconv.Itoa(version.Version.Patch), nil
+}
+
+// getMajorVersion returns the major version of the configured JSON-RPC API
+// or 0 if it is unknown.
+func getMajorVersion(config administration.Configuration) int {
+    major, err := strconv.Atoi(strings.SplitN(config.KodiVersion, `.`, 2)[0])
+    if err != nil {
+        return 0
+    }
+    return major
+}
+
+// adaptParamsToVersion converts the params of methods whose params changed
+// between the versions of the JSON-RPC API. The params are created in the
+// old format and converted if the configured version is newer or unknown.
+func adaptParamsToVersion(config administration.Configuration, method string, params map[string]interface{}) {
+    major := getMajorVersion(config)
+    if method == `Player.Seek` && (major == 0 || major >= seekObjectVersion) {
+        switch value := params[`value`].(type) {
+        case string:
+            params[`value`] = map[string]interface{} {`step`: value}
+        case int, float64:
+            params[`value`] = map[string]interface{} {`percentage`: value}
+        case map[string]int, Time:
+            params[`value`] = map[string]interface{} {`time`: value}
+        }
+    }
+}
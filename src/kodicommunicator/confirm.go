@@ -0,0 +1,34 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+)
+
+// confirmCommand asks the user on the terminal whether the destructive
+// command should really be executed. It returns an error if the user
+// declines or if nobody can be asked because stdin is no terminal.
+func confirmCommand(config administration.Configuration, command *Command) error {
+    if !command.Destructive || config.AssumeYes {
+        return nil
+    }
+    if !isTerminal(os.Stdin) {
+        return errors.New(`The command ` + command.CliName + ` needs to be confirmed. Add --yes to run it without confirmation.`)
+    }
+    fmt.Fprint(os.Stderr, `Do you really want to run `, command.CliName, `? [y/N] `)
+    answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    if err != nil && err != io.EOF {
+        return err
+    }
+    answer = strings.ToLower(strings.TrimSpace(answer))
+    if answer != `y` && answer != `yes` {
+        return errors.New(`The command ` + command.CliName + ` was not executed.`)
+    }
+    return nil
+}
@@ -0,0 +1,235 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "bufio"
+    "bytes"
+    "crypto/rand"
+    "crypto/sha1"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "io"
+    "net"
+    "net/http"
+    "strings"
+)
+
+const (
+    // webSocketGUID is appended to the key of the handshake
+    // to calculate the expected accept value (RFC 6455).
+    webSocketGUID = `258EAFA5-E914-47DA-95CA-C5AB0DC85B11`
+
+    opcodeContinuation = 0x0
+    opcodeText = 0x1
+    opcodeBinary = 0x2
+    opcodeClose = 0x8
+    opcodePing = 0x9
+    opcodePong = 0xA
+)
+
+// Notification is an event Kodi sends to all connected WebSocket
+// clients, e.g. Player.OnPlay.
+type Notification struct {
+    Method string `json:"method"`
+    Params NotificationParams `json:"params"`
+}
+
+// NotificationParams contains the sender and the event
+// specific data of a Notification.
+type NotificationParams struct {
+    Sender string `json:"sender"`
+    Data json.RawMessage `json:"data"`
+}
+
+// formatNotification creates a single line containing the method
+// and the data of the notification.
+func formatNotification(notification Notification) string {
+    var data bytes.Buffer
+    if err := json.Compact(&data, notification.Params.Data); err != nil || data.Len() == 0 {
+        return notification.Method
+    }
+    return notification.Method + ` ` + data.String()
+}
+
+// webSocketConnection is a client connection to Kodi's WebSocket server.
+type webSocketConnection struct {
+    conn net.Conn
+    reader *bufio.Reader
+}
+
+// subscribeNotifications connects to Kodi's WebSocket server and passes
+// every notification to handle until the connection is closed or handle
+// returns an error.
+func subscribeNotifications(config administration.Configuration, handle func(notification Notification) error) error {
+    connection, err := dialWebSocket(config)
+    if err != nil {
+        return err
+    }
+    defer connection.conn.Close()
+
+    for {
+        message, err := connection.readMessage()
+        if err != nil {
+            return err
+        }
+        var notification Notification
+        if err := json.Unmarshal(message, &notification); err != nil || len(notification.Method) == 0 {
+            // responses to requests and malformed messages are no notifications
+            continue
+        }
+        if err := handle(notification); err != nil {
+            return err
+        }
+    }
+}
+
+// dialWebSocket opens the connection to the configured WebSocket port
+// and performs the opening handshake.
+func dialWebSocket(config administration.Configuration) (*webSocketConnection, error) {
+    connectTimeout, err := config.GetConnectTimeout()
+    if err != nil {
+        return nil, err
+    }
+    address := net.JoinHostPort(strings.Trim(config.Host, `[]`), config.GetWebSocketPort())
+    conn, err := net.DialTimeout(`tcp`, address, connectTimeout)
+    if err != nil {
+        return nil, createNetworkError(address, err)
+    }
+
+    keyBytes := make([]byte, 16)
+    if _, err := rand.Read(keyBytes); err != nil {
+        conn.Close()
+        return nil, err
+    }
+    key := base64.StdEncoding.EncodeToString(keyBytes)
+    handshake := "GET /jsonrpc HTTP/1.1\r\n" +
+        "Host: " + address + "\r\n" +
+        "Upgrade: websocket\r\n" +
+        "Connection: Upgrade\r\n" +
+        "Sec-WebSocket-Key: " + key + "\r\n" +
+        "Sec-WebSocket-Version: 13\r\n\r\n"
+    if _, err := io.WriteString(conn, handshake); err != nil {
+        conn.Close()
+        return nil, err
+    }
+
+    reader := bufio.NewReader(conn)
+    response, err := http.ReadResponse(reader, nil)
+    if err != nil {
+        conn.Close()
+        return nil, err
+    }
+    response.Body.Close()
+    if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get(`Sec-WebSocket-Accept`) != createAcceptKey(key) {
+        conn.Close()
+        return nil, errors.New(`The WebSocket handshake with ` + address + ` failed: ` + response.Status)
+    }
+    return &webSocketConnection{conn, reader}, nil
+}
+
+// createAcceptKey calculates the accept value the server has to
+// send for the key of the handshake.
+func createAcceptKey(key string) string {
+    hash := sha1.Sum([]byte(key + webSocketGUID))
+    return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// readMessage reads frames until a complete text or binary message was
+// received. Pings are answered, a close frame ends the connection.
+func (self *webSocketConnection) readMessage() ([]byte, error) {
+    var message []byte
+    for {
+        fin, opcode, payload, err := self.readFrame()
+        if err != nil {
+            return nil, err
+        }
+        switch opcode {
+        case opcodeText, opcodeBinary, opcodeContinuation:
+            message = append(message, payload...)
+            if fin {
+                return message, nil
+            }
+        case opcodePing:
+            if err := self.writeFrame(opcodePong, payload); err != nil {
+                return nil, err
+            }
+        case opcodeClose:
+            self.writeFrame(opcodeClose, nil)
+            return nil, io.EOF
+        }
+    }
+}
+
+// readFrame reads a single frame and returns whether it is the final
+// fragment of a message, its opcode and its unmasked payload.
+func (self *webSocketConnection) readFrame() (bool, byte, []byte, error) {
+    header := make([]byte, 2)
+    if _, err := io.ReadFull(self.reader, header); err != nil {
+        return false, 0, nil, err
+    }
+    fin := header[0] & 0x80 != 0
+    opcode := header[0] & 0x0F
+    masked := header[1] & 0x80 != 0
+    length := uint64(header[1] & 0x7F)
+
+    if length == 126 {
+        extended := make([]byte, 2)
+        if _, err := io.ReadFull(self.reader, extended); err != nil {
+            return false, 0, nil, err
+        }
+        length = uint64(binary.BigEndian.Uint16(extended))
+    } else if length == 127 {
+        extended := make([]byte, 8)
+        if _, err := io.ReadFull(self.reader, extended); err != nil {
+            return false, 0, nil, err
+        }
+        length = binary.BigEndian.Uint64(extended)
+    }
+
+    mask := make([]byte, 4)
+    if masked {
+        if _, err := io.ReadFull(self.reader, mask); err != nil {
+            return false, 0, nil, err
+        }
+    }
+    payload := make([]byte, length)
+    if _, err := io.ReadFull(self.reader, payload); err != nil {
+        return false, 0, nil, err
+    }
+    if masked {
+        for i := range payload {
+            payload[i] ^= mask[i % 4]
+        }
+    }
+    return fin, opcode, payload, nil
+}
+
+// writeFrame sends a single final frame. Frames sent by a client
+// always need to be masked.
+func (self *webSocketConnection) writeFrame(opcode byte, payload []byte) error {
+    frame := []byte{0x80 | opcode}
+    length := len(payload)
+    if length < 126 {
+        frame = append(frame, 0x80 | byte(length))
+    } else if length <= 0xFFFF {
+        frame = append(frame, 0x80 | 126, 0, 0)
+        binary.BigEndian.PutUint16(frame[2:], uint16(length))
+    } else {
+        frame = append(frame, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0)
+        binary.BigEndian.PutUint64(frame[2:], uint64(length))
+    }
+
+    mask := make([]byte, 4)
+    if _, err := rand.Read(mask); err != nil {
+        return err
+    }
+    frame = append(frame, mask...)
+    for i, b := range payload {
+        frame = append(frame, b ^ mask[i % 4])
+    }
+    _, err := self.conn.Write(frame)
+    return err
+}
@@ -0,0 +1,63 @@
+package kodicommunicator
+
+import (
+    "encoding/json"
+    "errors"
+    "strconv"
+    "strings"
+)
+
+// lastResult is the result of the last successful request. Expectations
+// are checked against it after a command was executed.
+var lastResult json.RawMessage
+
+// checkExpectations compares the fields of result with the expectations like
+// "speed=0". Fields of nested objects and arrays are separated by dots,
+// e.g. "time.minutes=3" or "0.playerid=1".
+func checkExpectations(expectations []string, result json.RawMessage) error {
+    if len(result) == 0 {
+        return errors.New(`The command returned no response to check the expectations against.`)
+    }
+    var response interface{}
+    if err := json.Unmarshal(result, &response); err != nil {
+        return err
+    }
+    for _, expectation := range expectations {
+        pathValue := strings.SplitN(expectation, `=`, 2)
+        value, success := lookupPath(response, pathValue[0])
+        if !success {
+            return errors.New(`The response contains no field ` + pathValue[0] + `.`)
+        }
+        actual, err := formatValue(value)
+        if err != nil {
+            return err
+        }
+        if actual != pathValue[1] {
+            return errors.New(`Expected ` + pathValue[0] + ` to be ` + pathValue[1] + ` but it is ` + actual + `.`)
+        }
+    }
+    return nil
+}
+
+// lookupPath returns the value at the dotted path inside of value.
+// The second return value is false if there is no such field.
+func lookupPath(value interface{}, path string) (interface{}, bool) {
+    for _, key := range strings.Split(path, `.`) {
+        switch container := value.(type) {
+        case map[string]interface{}:
+            var success bool
+            if value, success = container[key]; !success {
+                return nil, false
+            }
+        case []interface{}:
+            index, err := strconv.Atoi(key)
+            if err != nil || index < 0 || index >= len(container) {
+                return nil, false
+            }
+            value = container[index]
+        default:
+            return nil, false
+        }
+    }
+    return value, true
+}
@@ -0,0 +1,153 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "errors"
+    "strings"
+)
+
+// mediaTypes are the types of media Files.GetDirectory can filter by.
+var mediaTypes = []string{`files`, `music`, `video`, `pictures`, `programs`}
+
+// filePlaylists maps the types of files to the playlist they are queued in.
+// Files of other types are queued in the video playlist.
+var filePlaylists = map[string]string {
+    `song`: `audio`,
+    `album`: `audio`,
+    `artist`: `audio`,
+    `picture`: `picture`,
+}
+
+// File is an entry of a directory returned by Files.GetDirectory.
+type File struct {
+    File string `json:"file"`
+    FileType string `json:"filetype"`
+    Label string `json:"label"`
+    Type string `json:"type"`
+}
+
+// DisplayType returns the library type of the file, e.g. "song",
+// or whether it is a file or a directory if the type is unknown.
+func (self File) DisplayType() string {
+    if len(self.Type) > 0 && self.Type != `unknown` {
+        return self.Type
+    }
+    return self.FileType
+}
+
+// String returns the type, the label and the path of the file.
+func (self File) String() string {
+    return self.DisplayType() + ` - ` + self.Label + ` (` + self.File + `)`
+}
+
+// createDirectoryParams creates the params of Files.GetDirectory from
+// the media type and the path.
+func createDirectoryParams(params []string) (map[string]interface{}, error) {
+    if len(params) < 2 {
+        return map[string]interface{}{}, errors.New(`Not enough parameters. See "help browse" for usage information.`)
+    }
+    if !isMediaType(params[0]) {
+        return map[string]interface{}{}, errors.New(`Illegal media ` + params[0] + `. See "help browse" for usage information.`)
+    }
+    return map[string]interface{} {
+        `directory`:strings.Join(params[1:], ` `),
+        `media`:params[0],
+    }, nil
+}
+
+// parseDirectory returns the entries of the result of Files.GetDirectory.
+func parseDirectory(result json.RawMessage) ([]File, error) {
+    var directory struct {
+        Files []File `json:"files"`
+    }
+    err := json.Unmarshal(result, &directory)
+    return directory.Files, err
+}
+
+// formatDirectory lists the entries of a directory with their types
+// and paths, one entry per line.
+func formatDirectory(files []File) string {
+    if len(files) == 0 {
+        return `The directory is empty.`
+    }
+    lines := make([]string, len(files))
+    for i, file := range files {
+        lines[i] = file.String()
+    }
+    return strings.Join(lines, "\n")
+}
+
+// createFileRows creates the rows of the table listing the entries of a directory.
+func createFileRows(files []File) [][]string {
+    rows := make([][]string, len(files))
+    for i, file := range files {
+        rows[i] = []string{file.Label, file.DisplayType(), file.File}
+    }
+    return rows
+}
+
+// createFileItems turns the entries of a directory into items
+// which can be selected.
+func createFileItems(files []File) []selectableItem {
+    items := make([]selectableItem, len(files))
+    for i, file := range files {
+        item := map[string]interface{} {`file`: file.File}
+        if file.FileType == `directory` {
+            item = map[string]interface{} {`directory`: file.File}
+        }
+        playlist, success := filePlaylists[file.Type]
+        if !success {
+            playlist = `video`
+        }
+        items[i] = selectableItem{file.String(), item, playlist}
+    }
+    return items
+}
+
+// Source is a media source returned by Files.GetSources.
+type Source struct {
+    File string `json:"file"`
+    Label string `json:"label"`
+}
+
+// getSources lists the sources configured for the media type.
+func getSources(config administration.Configuration, mediaType string) (string, error) {
+    if !isMediaType(mediaType) {
+        return ``, errors.New(`Illegal media ` + mediaType + `. See "help sources" for usage information.`)
+    }
+    var sources struct {
+        Sources []Source `json:"sources"`
+    }
+    result, err := callMethod(config, `Files.GetSources`, map[string]interface{} {
+        `media`: mediaType,
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &sources)
+    }
+    if err != nil {
+        return ``, err
+    }
+    plain := `No sources configured.`
+    lines := make([]string, len(sources.Sources))
+    rows := make([][]string, len(sources.Sources))
+    for i, source := range sources.Sources {
+        lines[i] = source.Label + ` (` + source.File + `)`
+        rows[i] = []string{source.Label, source.File}
+    }
+    if len(lines) > 0 {
+        plain = strings.Join(lines, "\n")
+    }
+    return formatListing(config, sources.Sources, []string{`Name`, `Path`}, rows, plain)
+}
+
+// isMediaType returns true if mediaType is one of mediaTypes.
+func isMediaType(mediaType string) bool {
+    for _, knownType := range mediaTypes {
+        if mediaType == knownType {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,42 @@
+package kodicommunicator
+
+import (
+    "encoding/json"
+    "strconv"
+    "strings"
+)
+
+// guiProperties are the properties requested by guistate.
+var guiProperties = []string{`currentwindow`, `currentcontrol`, `fullscreen`, `stereoscopicmode`}
+
+// GUIState is the state of the user interface returned by GUI.GetProperties.
+type GUIState struct {
+    CurrentWindow struct {
+        ID int `json:"id"`
+        Label string `json:"label"`
+    } `json:"currentwindow"`
+    CurrentControl struct {
+        Label string `json:"label"`
+    } `json:"currentcontrol"`
+    Fullscreen bool `json:"fullscreen"`
+    StereoscopicMode struct {
+        Label string `json:"label"`
+        Mode string `json:"mode"`
+    } `json:"stereoscopicmode"`
+}
+
+// formatGUIState displays the current window, the focused control, the
+// fullscreen state and the stereoscopic mode, one property per line.
+func formatGUIState(result json.RawMessage) (string, error) {
+    var state GUIState
+    if err := json.Unmarshal(result, &state); err != nil {
+        return ``, err
+    }
+    lines := []string{
+        `Window: ` + state.CurrentWindow.Label + ` (` + strconv.Itoa(state.CurrentWindow.ID) + `)`,
+        `Control: ` + state.CurrentControl.Label,
+        `Fullscreen: ` + strconv.FormatBool(state.Fullscreen),
+        `Stereoscopic mode: ` + state.StereoscopicMode.Mode,
+    }
+    return strings.Join(lines, "\n"), nil
+}
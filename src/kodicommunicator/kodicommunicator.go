@@ -2,13 +2,22 @@ package kodicommunicator
 
 import (
     "administration"
+    "logging"
 
+    "bytes"
     "encoding/json"
     "errors"
+    "fmt"
     "io/ioutil"
+    "math"
+    "net"
     "net/http"
+    "net/url"
+    "os"
+    "sort"
     "strings"
     "strconv"
+    "time"
 )
 
 // ErrorResponse is the foundation for the JSONRPC
@@ -19,6 +28,14 @@ type ErrorResponse struct {
     JsonRPC string `json:"jsonrpc"`
 }
 
+// Response is the foundation for every JSONRPC response
+// returned by Kodi. Result contains the raw data of a
+// successful call.
+type Response struct {
+    ErrorResponse
+    Result json.RawMessage `json:"result"`
+}
+
 // Error is the part of the JSONRPC-Errorresponse 
 // which contains the error-data.
 type Error struct {
@@ -43,15 +60,42 @@ type Stack struct {
     Message string `json:"message"`
 }
 
+// JsonError is the error returned if Kodi answered a request
+// with an ErrorResponse.
+type JsonError struct {
+    Code int
+    Message string
+}
+
+// Error returns the readable message of the error.
+func (self *JsonError) Error() string {
+    return self.Message
+}
+
 // Command represents a command which can be sent to Kodi. 
 // It also represents a documentation and a translation from CLI-command
 // to a command Kodi understands.
 type Command struct {
-    CliName string
-    KodiName string
-    Description string
-    ParametersDescription map[string]string
-    CreateParameterMap func(params []string) (map[string]interface{}, error)
+    CliName string `json:"cliname"`
+    KodiName string `json:"kodiname"`
+    Description string `json:"description"`
+    ParametersDescription map[string]string `json:"parameters"`
+    // Flags contains all flags which are passed to the command as parameters.
+    Flags []string `json:"flags,omitempty"`
+    // Subcommands are used instead of the command if the first
+    // parameter matches their name.
+    Subcommands map[string]*Command `json:"subcommands,omitempty"`
+    // LongRunning shows a spinner while waiting for Kodi's response.
+    LongRunning bool `json:"-"`
+    // Destructive commands need to be confirmed before they are executed.
+    Destructive bool `json:"destructive,omitempty"`
+    CreateParameterMap func(params []string) (map[string]interface{}, error) `json:"-"`
+    // FormatResult turns the result returned by Kodi into the output
+    // which is displayed to the user.
+    FormatResult func(result json.RawMessage) (string, error) `json:"-"`
+    // Execute replaces the single request built by CreateParameterMap
+    // for commands which need more than one request to Kodi.
+    Execute func(config administration.Configuration, params []string) (string, error) `json:"-"`
 }
 
 // CommandRequest represents all parameters of a JSONRPC call.
@@ -70,40 +114,87 @@ func (self *CommandRequest) SetValues(method string, params map[string]interface
     self.Params = params
 }
 
+const (
+    // startupErrorCode is returned by Kodi if it fails to execute a method,
+    // e.g. because it is still starting after boot.
+    startupErrorCode = -32100
+    // startupRetries is the number of times a request failing with
+    // startupErrorCode is sent again.
+    startupRetries = 3
+    startupRetryDelay = 2 * time.Second
+    // attemptDelay is the time between two attempts of --repeat-until-success.
+    attemptDelay = 500 * time.Millisecond
+    defaultNotificationTitle = `krm`
+    // minDisplayTime is the shortest time in milliseconds Kodi displays a notification.
+    minDisplayTime = 1500
+    // stickyDisplayTime is the time in milliseconds a sticky notification
+    // is displayed because Kodi has no notifications without timeout.
+    stickyDisplayTime = 24 * 60 * 60 * 1000
+    defaultRecentCount = 10
+    // fadeStepInterval is the time between two volume changes of a fade.
+    fadeStepInterval = 200 * time.Millisecond
+    maxSuggestionDistance = 2
+    maxSuggestions = 3
+)
+
 var (
+    stereoscopicModes = []string {
+        `toggle`,
+        `off`,
+        `split_vertical`,
+        `split_horizontal`,
+        `row_interleaved`,
+        `hardware_based`,
+        `anaglyph_cyan_red`,
+        `anaglyph_green_magenta`,
+        `monoscopic`,
+    }
+
     CommandMap = map[string]*Command {
         // Player 
         `play`: &Command {
             CliName: `play`, 
             KodiName: `Player.PlayPause`, 
-            Description: `Resumes the current playback from pause state.`,
+            Description: `Resumes the current playback from pause state. Resumes the video played last if nothing is playing.`,
             ParametersDescription: map[string]string {},
-            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
-                return map[string]interface{} {
-                    `playerid`:1,
-                }, nil
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return playPause(config, true)
             },
         },
         `pause`: &Command {
             CliName: `pause`, 
             KodiName: `Player.PlayPause`, 
-            Description: `Pauses the current playback.`,
+            Description: `Pauses the current playback. Succeeds if nothing is playing.`,
             ParametersDescription: map[string]string {},
-            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
-                return map[string]interface{} {
-                    `playerid`:1,
-                }, nil
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return playPause(config, false)
             },
         },
         `stop`: &Command {
             CliName: `stop`, 
             KodiName: `Player.Stop`, 
-            Description: `Stops the current playback.`,
+            Description: `Stops the current playback. Succeeds if nothing is playing.`,
             ParametersDescription: map[string]string {},
-            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
-                return map[string]interface{} {
-                    `playerid`:1,
-                }, nil
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return stopPlayback(config)
+            },
+        },
+        `off`: &Command {
+            CliName: `off`, 
+            KodiName: `Player.Stop`, 
+            Description: `Stops all players and mutes the audio if configured with --off-mutes=true.`,
+            ParametersDescription: map[string]string {},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                if err := stopAllPlayers(config); err != nil {
+                    return ``, err
+                }
+                if config.OffMutes {
+                    _, err := callMethod(config, `Application.SetMute`, map[string]interface{} {
+                        `mute`: true,
+                    })
+                    return ``, err
+                }
+                return ``, nil
             },
         },
         `mute`: &Command {
@@ -117,6 +208,100 @@ var (
                 }, nil
             },
         },
+        `quit`: &Command {
+            CliName: `quit`, 
+            KodiName: `Application.Quit`, 
+            Description: `Quits Kodi.`,
+            Destructive: true,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{}{}, nil
+            },
+        },
+        `volpct`: &Command {
+            CliName: `volpct`, 
+            KodiName: `Application.SetVolume`, 
+            Description: `Changes the volume by the given percentage points.`,
+            ParametersDescription: map[string]string {
+                `-/+n`: `Lower/raise the volume by n percentage points.`,
+                `--silent-volume`: `Hides the volume bar while changing the volume, see "help".`,
+            },
+            Flags: []string{`--silent-volume`},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                params, silent := removeFlag(params, `--silent-volume`)
+                if len(params) < 1 {
+                    return ``, errors.New(`Not enough parameters. See "help volpct" for usage information.`)
+                }
+                delta, err := strconv.Atoi(params[0])
+                if err != nil {
+                    return ``, errors.New(`Illegal parameter. See "help volpct" for usage information.`)
+                }
+                volume, err := getVolume(config)
+                if err != nil {
+                    return ``, err
+                }
+                return ``, changeVolume(config, silent, func() error {
+                    _, err := callMethod(config, `Application.SetVolume`, map[string]interface{} {
+                        `volume`: clampVolume(volume + delta),
+                    })
+                    return err
+                })
+            },
+        },
+        `fade`: &Command {
+            CliName: `fade`, 
+            KodiName: `Application.SetVolume`, 
+            Description: `Fades the volume smoothly to the given value.`,
+            ParametersDescription: map[string]string {
+                `volume`: `The target volume between 0 and 100.`,
+                `duration`: `The duration of the fade, e.g. "3s". Plain numbers are milliseconds.`,
+                `--silent-volume`: `Hides the volume bar while fading, see "help".`,
+            },
+            Flags: []string{`--silent-volume`},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                params, silent := removeFlag(params, `--silent-volume`)
+                if len(params) < 2 {
+                    return ``, errors.New(`Not enough parameters. See "help fade" for usage information.`)
+                }
+                target, err := strconv.Atoi(params[0])
+                if err != nil {
+                    return ``, errors.New(`Illegal volume ` + params[0] + `.`)
+                }
+                milliseconds, err := parseMilliseconds(params[1])
+                if err != nil {
+                    return ``, err
+                }
+                return ``, changeVolume(config, silent, func() error {
+                    return fadeVolume(config, clampVolume(target), time.Duration(milliseconds) * time.Millisecond)
+                })
+            },
+        },
+        `osdvolup`: &Command {
+            CliName: `osdvolup`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Raises the volume by one step and shows the volume bar.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of steps.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`volumeup`,
+                }, nil
+            },
+        },
+        `osdvoldown`: &Command {
+            CliName: `osdvoldown`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Lowers the volume by one step and shows the volume bar.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of steps.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`volumedown`,
+                }, nil
+            },
+        },
         `seek`: &Command {
             CliName: `seek`, 
             KodiName: `Player.Seek`, 
@@ -124,7 +309,28 @@ var (
             ParametersDescription: map[string]string {
                 `-/+`: `Jump back/forth n seconds.`,
                 `--/++`: `Jump back/forth n seconds.`,
-                `[hh:]mm:ss`: `Junp to hours:minutes:seconds (hours optional)`,
+                `[hh:]mm:ss[.mmm]`: `Jump to hours:minutes:seconds.milliseconds (hours and milliseconds optional)`,
+                `frame -/+n`: `Jump back/forth n frames of the current video.`,
+            },
+            Subcommands: map[string]*Command {
+                `frame`: &Command {
+                    CliName: `frame`, 
+                    KodiName: `Player.Seek`, 
+                    Description: `Jumps back/forth the given number of frames.`,
+                    ParametersDescription: map[string]string {
+                        `-/+n`: `Jump back/forth n frames.`,
+                    },
+                    Execute: func(config administration.Configuration, params []string) (string, error) {
+                        if len(params) < 1 {
+                            return ``, errors.New(`Not enough parameters. See "help seek" for usage information.`)
+                        }
+                        frames, err := strconv.Atoi(params[0])
+                        if err != nil {
+                            return ``, errors.New(`Illegal parameter. See "help seek" for usage information.`)
+                        }
+                        return ``, seekFrames(config, frames)
+                    },
+                },
             },
             CreateParameterMap: func(params []string) (map[string]interface{}, error) {
                 if len(params) < 1 {
@@ -167,10 +373,18 @@ var (
                     if err != nil {
                         return nil, err
                     }
-                    seconds, err := parseTimeNumber(hms[1])
+                    secondsAndFraction := strings.SplitN(hms[1], `.`, 2)
+                    seconds, err := parseTimeNumber(secondsAndFraction[0])
                     if err != nil {
                         return nil, err
                     }
+                    if len(secondsAndFraction) == 2 {
+                        milliseconds, err := parseMillisecondFraction(secondsAndFraction[1])
+                        if err != nil {
+                            return nil, err
+                        }
+                        timeMap[`milliseconds`] = milliseconds
+                    }
                     timeMap[`minutes`] = minutes
                     timeMap[`seconds`] = seconds
                     return map[string]interface{} {
@@ -195,6 +409,291 @@ var (
                 }, nil
             },
         },
+        `ff`: &Command {
+            CliName: `ff`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Steps to the next fast forward speed like the button of a remote.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of speeds to step.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`fastforward`,
+                }, nil
+            },
+        },
+        `rw`: &Command {
+            CliName: `rw`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Steps to the next rewind speed like the button of a remote.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of speeds to step.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`rewind`,
+                }, nil
+            },
+        },
+        `stepfwd`: &Command {
+            CliName: `stepfwd`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Steps forward in the current playback.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of steps.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`stepforward`,
+                }, nil
+            },
+        },
+        `stepback`: &Command {
+            CliName: `stepback`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Steps back in the current playback.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of steps.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`stepback`,
+                }, nil
+            },
+        },
+        `bigfwd`: &Command {
+            CliName: `bigfwd`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Takes a big step forward in the current playback.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of steps.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`bigstepforward`,
+                }, nil
+            },
+        },
+        `bigback`: &Command {
+            CliName: `bigback`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Takes a big step back in the current playback.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of steps.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`bigstepback`,
+                }, nil
+            },
+        },
+        `zoom`: &Command {
+            CliName: `zoom`, 
+            KodiName: `Player.Zoom`, 
+            Description: `Zooms the current picture or video.`,
+            ParametersDescription: map[string]string {
+                `in/out/level`: `Zoom in or out by one level or to a level from 1 to 10.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help zoom" for usage information.`)
+                }
+                var zoom interface{} = params[0]
+                if params[0] != `in` && params[0] != `out` {
+                    level, err := strconv.Atoi(params[0])
+                    if err != nil || level < 1 || level > 10 {
+                        return map[string]interface{}{}, errors.New(`Illegal parameter. See "help zoom" for usage information.`)
+                    }
+                    zoom = level
+                }
+                return map[string]interface{} {
+                    `playerid`:1,
+                    `zoom`:zoom,
+                }, nil
+            },
+        },
+        `rotate`: &Command {
+            CliName: `rotate`, 
+            KodiName: `Player.Rotate`, 
+            Description: `Rotates the current picture.`,
+            ParametersDescription: map[string]string {
+                `clockwise/counterclockwise`: `The direction to rotate in, clockwise if omitted.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                value := `clockwise`
+                if len(params) > 0 {
+                    if params[0] != `clockwise` && params[0] != `counterclockwise` {
+                        return map[string]interface{}{}, errors.New(`Illegal parameter. See "help rotate" for usage information.`)
+                    }
+                    value = params[0]
+                }
+                return map[string]interface{} {
+                    `playerid`:2,
+                    `value`:value,
+                }, nil
+            },
+        },
+        `pan`: &Command {
+            CliName: `pan`, 
+            KodiName: `Player.Move`, 
+            Description: `Moves the visible part of a zoomed picture.`,
+            ParametersDescription: map[string]string {
+                `direction`: `The direction to move in, one of left, right, up and down.`,
+                `n`: `(optional) The number of steps.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help pan" for usage information.`)
+                }
+                switch params[0] {
+                case `left`, `right`, `up`, `down`:
+                    return map[string]interface{} {
+                        `playerid`:2,
+                        `direction`:params[0],
+                    }, nil
+                }
+                return map[string]interface{}{}, errors.New(`Illegal parameter. See "help pan" for usage information.`)
+            },
+        },
+        `partymodetoggle`: &Command {
+            CliName: `partymodetoggle`, 
+            KodiName: `Player.SetPartymode`, 
+            Description: `Switches the party mode of the active player on or off.`,
+            ParametersDescription: map[string]string {
+                `on/off`: `(optional) Switch party mode on or off. Toggles if omitted.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                var partymode interface{} = `toggle`
+                if len(params) > 0 {
+                    if params[0] == `on` {
+                        partymode = true
+                    } else if params[0] == `off` {
+                        partymode = false
+                    } else if params[0] != `toggle` {
+                        return map[string]interface{}{}, errors.New(`Illegal parameter. See "help partymodetoggle" for usage information.`)
+                    }
+                }
+                return map[string]interface{} {
+                    `playerid`:1,
+                    `partymode`:partymode,
+                }, nil
+            },
+        },
+        `tracks`: &Command {
+            CliName: `tracks`, 
+            KodiName: `Player.GetProperties`, 
+            Description: `Lists the audio streams and subtitles of the current playback and marks the current ones.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `playerid`:1,
+                    `properties`:trackProperties,
+                }, nil
+            },
+            FormatResult: formatTracks,
+        },
+        `art`: &Command {
+            CliName: `art`, 
+            KodiName: `Files.PrepareDownload`, 
+            Description: `Displays the URL of the thumbnail or fanart of the current item, e.g. the cover of an album.`,
+            ParametersDescription: map[string]string {
+                `type`: `(optional) One of ` + strings.Join(artTypes, `, `) + `. Defaults to thumbnail.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                artType := artTypes[0]
+                if len(params) > 0 {
+                    artType = params[0]
+                }
+                for _, knownType := range artTypes {
+                    if artType == knownType {
+                        return getCurrentArtURL(config, artType)
+                    }
+                }
+                return ``, errors.New(`Illegal type ` + artType + `. See "help art" for usage information.`)
+            },
+        },
+        `open`: &Command {
+            CliName: `open`, 
+            KodiName: `Player.Open`, 
+            Description: `Plays a file or URL.`,
+            ParametersDescription: map[string]string {
+                `path`: `The path or URL of the file, e.g. "/movies/Alien.mkv" or "smb://nas/movies/Alien.mkv".`,
+                `--resume`: `Continues the playback at the saved position.`,
+            },
+            Flags: []string{`--resume`},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                path, resume := removeFlag(params, `--resume`)
+                if len(path) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help open" for usage information.`)
+                }
+                paramMap := map[string]interface{} {
+                    `item`:map[string]interface{} {
+                        `file`:strings.Join(path, ` `),
+                    },
+                }
+                if resume {
+                    paramMap[`options`] = map[string]interface{} {
+                        `resume`:true,
+                    }
+                }
+                return paramMap, nil
+            },
+        },
+        `resumelast`: &Command {
+            CliName: `resumelast`, 
+            KodiName: `Player.Open`, 
+            Description: `Returns to the item which was opened last or to the window which was activated last by this tool.`,
+            ParametersDescription: map[string]string {
+                `item/window`: `(optional) Return to the item or to the window. Defaults to the item if one was opened.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                target := ``
+                if len(params) > 0 {
+                    target = params[0]
+                }
+                return resumeLast(config, target)
+            },
+        },
+        `restartplayback`: &Command {
+            CliName: `restartplayback`, 
+            KodiName: `Player.Open`, 
+            Description: `Stops the current playback and starts it again at the same time, e.g. if a stream stalls.`,
+            ParametersDescription: map[string]string {},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return restartPlayback(config)
+            },
+        },
+        `progress`: &Command {
+            CliName: `progress`, 
+            KodiName: `Player.GetProperties`, 
+            Description: `Displays the progress of the current playback until Ctrl-C is pressed.`,
+            ParametersDescription: map[string]string {},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return ``, watchProgress(config)
+            },
+        },
+        `nowplaying`: &Command {
+            CliName: `nowplaying`, 
+            KodiName: `Player.GetItem`, 
+            Description: `Displays the current item and its progress.`,
+            ParametersDescription: map[string]string {
+                `--output-template=template`: `(optional) A Go template for the output using .Title, .Type, .Elapsed, .Duration, .Percentage, .Speed and .Playing.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return formatNowPlaying(config)
+            },
+        },
+        `statusline`: &Command {
+            CliName: `statusline`, 
+            KodiName: `Player.GetItem`, 
+            Description: `Prints a single line for status bars like tmux or polybar. Prints nothing if nothing is playing.`,
+            ParametersDescription: map[string]string {
+                `--output-template=template`: `(optional) A Go template for the output, see "help nowplaying".`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return formatStatusLine(config)
+            },
+        },
         
         // Input
         `action`: &Command {
@@ -278,124 +777,1677 @@ var (
                 return map[string]interface{}{}, nil
             },
         },
-        
-        // 
-        `notify`: &Command {
-            CliName: `notify`, 
-            KodiName: `GUI.ShowNotification`, 
-            Description: `Displays a notification on the screen.`,
-            ParametersDescription: map[string]string {
-                `title`: `The title of the notification.`,
-                `message`: `The message of the notification.`,
-                `displaytime`: `(optional) The time in milliseconds the notification is displayed.`,
+        `aspect`: &Command {
+            CliName: `aspect`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Cycles through the aspect ratios of the current video.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`aspectratio`,
+                }, nil
             },
+        },
+        `osd`: &Command {
+            CliName: `osd`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Shows or hides the on screen display of the current video.`,
+            ParametersDescription: map[string]string {},
             CreateParameterMap: func(params []string) (map[string]interface{}, error) {
-                return map[string]interface{}{}, nil
+                return map[string]interface{} {
+                    `action`:`osd`,
+                }, nil
             },
         },
-        `clean`: &Command {
-            CliName: `clean`, 
-            KodiName: `VideoLibrary.Clean`, 
-            Description: `Cleans the video library from non-existent items.`,
+        `playerdebug`: &Command {
+            CliName: `playerdebug`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Shows or hides the debug information of the player, e.g. to troubleshoot the playback.`,
             ParametersDescription: map[string]string {},
             CreateParameterMap: func(params []string) (map[string]interface{}, error) {
-                return map[string]interface{}{}, nil
+                return map[string]interface{} {
+                    `action`:`playerdebug`,
+                }, nil
             },
         },
-        `update`: &Command {
-            CliName: `update`, 
-            KodiName: `VideoLibrary.Scan`, 
-            Description: `Scans the video sources for new library items.`,
+        `viewmode`: &Command {
+            CliName: `viewmode`, 
+            KodiName: `Player.GetViewMode`, 
+            Description: `Displays the view mode, the zoom and the pixel ratio of the current video, e.g. after changing the aspect.`,
             ParametersDescription: map[string]string {},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return getViewMode(config)
+            },
+        },
+        `subdelay`: &Command {
+            CliName: `subdelay`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Adjusts the subtitle delay.`,
+            ParametersDescription: map[string]string {
+                `-/+`: `Decrease/increase the subtitle delay by one step.`,
+                `n`: `(optional) The number of steps.`,
+            },
             CreateParameterMap: func(params []string) (map[string]interface{}, error) {
-                return map[string]interface{}{}, nil
+                return createPlusMinusAction(params, `subdelay`, `subtitledelayplus`, `subtitledelayminus`)
             },
         },
-    }
-)
-
-// parseTimeNumber parses a number and makes sure that
-// the number is >= 0 and <= 59
-func parseTimeNumber(number string) (int, error) {
-    num, err := strconv.Atoi(number)
-    if err != nil {
-        return 0, err
-    } else if num > 59 || num < 0 {
-        return 0, errors.New(`A time-number needs to be between 0 and 59, but was ` + number)
-    } else {
-        return num, nil
-    }
-}
-
-// GetCommandForName returns a copy of the Command related to the CliName passed
-// if it exists. 
-func GetCommandForName(cmd string) (Command, bool) {
-    command, success := CommandMap[cmd]
-    return *command, success 
-}
-
-// getRepeatCount returns for some allowed actions the number how often this action
-// should be executed.
-func getRepeatCount(action string, params *[]string) int {
-    if len(*params) > 0 && (action == `down` || action == `up` || action == `left` || action == `right`) {
-        num, err := strconv.Atoi((*params)[len(*params) - 1])
-        if err != nil || num < 1 {
-            return 1
-        }
-        *params = (*params)[:len(*params) - 1]
-        return num
-    }
-    return 1
-}
-
-// ExecuteCommand takes the action, looks up the appropriate JSON-RPC command
-// and sends the request to the configured address.
-func ExecuteCommand(config administration.Configuration, action string, params []string) error {
+        `audiodelay`: &Command {
+            CliName: `audiodelay`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Adjusts the audio delay.`,
+            ParametersDescription: map[string]string {
+                `-/+`: `Decrease/increase the audio delay by one step.`,
+                `n`: `(optional) The number of steps.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createPlusMinusAction(params, `audiodelay`, `audiodelayplus`, `audiodelayminus`)
+            },
+        },
+        `num`: &Command {
+            CliName: `num`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Enters digits, e.g. a channel number.`,
+            ParametersDescription: map[string]string {
+                `digits`: `The digits to enter one after another, e.g. "1 2 3" or "123".`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                if len(params) < 1 {
+                    return ``, errors.New(`Not enough parameters. See "help num" for usage information.`)
+                }
+                digits := strings.Join(params, ``)
+                for _, digit := range digits {
+                    if digit < '0' || digit > '9' {
+                        return ``, errors.New(`Illegal parameter. See "help num" for usage information.`)
+                    }
+                }
+                for _, digit := range digits {
+                    if _, err := callMethod(config, `Input.ExecuteAction`, map[string]interface{} {`action`: `number` + string(digit)}); err != nil {
+                        return ``, err
+                    }
+                }
+                return ``, nil
+            },
+        },
+        `button`: &Command {
+            CliName: `button`, 
+            KodiName: `Input.ButtonEvent`, 
+            Description: `Sends a button of a keymap.`,
+            ParametersDescription: map[string]string {
+                `button`: `The name of the button, e.g. "play".`,
+                `keymap`: `(optional) The keymap containing the button, e.g. "KB", "XG" or "R1". Defaults to "KB".`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help button" for usage information.`)
+                }
+                keymap := `KB`
+                if len(params) > 1 {
+                    keymap = params[1]
+                }
+                return map[string]interface{} {
+                    `button`:params[0],
+                    `keymap`:keymap,
+                }, nil
+            },
+        },
+        
+        // 
+        `notify`: &Command {
+            CliName: `notify`, 
+            KodiName: `GUI.ShowNotification`, 
+            Description: `Displays a notification on the screen.`,
+            ParametersDescription: map[string]string {
+                `title`: `(optional) The title of the notification. Defaults to "` + defaultNotificationTitle + `".`,
+                `message`: `The message of the notification. A text without keys is used as the message.`,
+                `displaytime`: `(optional) The time the notification is displayed, e.g. "5s" or "1500ms". Plain numbers are milliseconds. Kodi shows notifications at least 1500ms, "sticky" or 0 keep it displayed for a day.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                keyValues := parseKeyValueParams(params)
+                if _, hasMessage := keyValues[`message`]; !hasMessage && len(keyValues[`title`]) == 0 {
+                    keyValues = map[string]string {
+                        `message`: strings.Join(params, ` `),
+                    }
+                }
+                if len(keyValues[`title`]) == 0 {
+                    keyValues[`title`] = defaultNotificationTitle
+                }
+                if len(keyValues[`message`]) == 0 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help notify" for usage information.`)
+                }
+                paramMap := map[string]interface{} {
+                    `title`:keyValues[`title`],
+                    `message`:keyValues[`message`],
+                }
+                if displayTime, success := keyValues[`displaytime`]; success {
+                    milliseconds := stickyDisplayTime
+                    if displayTime != `sticky` {
+                        var err error
+                        if milliseconds, err = parseMilliseconds(displayTime); err != nil {
+                            return map[string]interface{}{}, err
+                        }
+                    }
+                    if milliseconds == 0 {
+                        milliseconds = stickyDisplayTime
+                    } else if milliseconds < minDisplayTime {
+                        milliseconds = minDisplayTime
+                    }
+                    paramMap[`displaytime`] = milliseconds
+                }
+                return paramMap, nil
+            },
+        },
+        `fullscreen`: &Command {
+            CliName: `fullscreen`, 
+            KodiName: `GUI.SetFullscreen`, 
+            Description: `Switches fullscreen on or off and displays the resulting state.`,
+            ParametersDescription: map[string]string {
+                `on/off`: `(optional) Switch fullscreen on or off. Toggles if omitted.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                var fullscreen interface{} = `toggle`
+                if len(params) > 0 {
+                    if params[0] == `on` {
+                        fullscreen = true
+                    } else if params[0] == `off` {
+                        fullscreen = false
+                    } else if params[0] != `toggle` {
+                        return map[string]interface{}{}, errors.New(`Illegal parameter. See "help fullscreen" for usage information.`)
+                    }
+                }
+                return map[string]interface{} {
+                    `fullscreen`:fullscreen,
+                }, nil
+            },
+            FormatResult: func(result json.RawMessage) (string, error) {
+                var fullscreen bool
+                if err := json.Unmarshal(result, &fullscreen); err != nil {
+                    return ``, err
+                }
+                return `Fullscreen: ` + strconv.FormatBool(fullscreen), nil
+            },
+        },
+        `stereo`: &Command {
+            CliName: `stereo`, 
+            KodiName: `GUI.SetStereoscopicMode`, 
+            Description: `Sets the stereoscopic 3D mode.`,
+            ParametersDescription: map[string]string {
+                `mode`: `(optional) One of ` + strings.Join(stereoscopicModes, `, `) + `. Defaults to toggle.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                mode := `toggle`
+                if len(params) > 0 {
+                    mode = params[0]
+                }
+                for _, stereoscopicMode := range stereoscopicModes {
+                    if mode == stereoscopicMode {
+                        return map[string]interface{} {
+                            `mode`:mode,
+                        }, nil
+                    }
+                }
+                return map[string]interface{}{}, errors.New(`Illegal mode ` + mode + `. See "help stereo" for usage information.`)
+            },
+        },
+        `guistate`: &Command {
+            CliName: `guistate`, 
+            KodiName: `GUI.GetProperties`, 
+            Description: `Displays the current window and control and whether Kodi is in fullscreen.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `properties`:guiProperties,
+                }, nil
+            },
+            FormatResult: formatGUIState,
+        },
+        `appinfo`: &Command {
+            CliName: `appinfo`, 
+            KodiName: `Application.GetProperties`, 
+            Description: `Displays the name and the version of the Kodi build.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `properties`:appProperties,
+                }, nil
+            },
+            FormatResult: formatAppInfo,
+        },
+        `getsetting`: &Command {
+            CliName: `getsetting`, 
+            KodiName: `Settings.GetSettingValue`, 
+            Description: `Displays the value of a setting.`,
+            ParametersDescription: map[string]string {
+                `id`: `The id of the setting, e.g. "videoplayer.autoplaynextitem".`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help getsetting" for usage information.`)
+                }
+                return map[string]interface{} {
+                    `setting`:params[0],
+                }, nil
+            },
+            FormatResult: func(result json.RawMessage) (string, error) {
+                var setting struct {
+                    Value interface{} `json:"value"`
+                }
+                if err := json.Unmarshal(result, &setting); err != nil {
+                    return ``, err
+                }
+                return formatValue(setting.Value)
+            },
+        },
+        `setsetting`: &Command {
+            CliName: `setsetting`, 
+            KodiName: `Settings.SetSettingValue`, 
+            Description: `Changes the value of a setting.`,
+            ParametersDescription: map[string]string {
+                `id`: `The id of the setting, e.g. "videoplayer.autoplaynextitem".`,
+                `value`: `The new value. true/false and numbers are sent as boolean and integer.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 2 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help setsetting" for usage information.`)
+                }
+                return map[string]interface{} {
+                    `setting`:params[0],
+                    `value`:CoerceValue(strings.Join(params[1:], ` `)),
+                }, nil
+            },
+        },
+        `resetsetting`: &Command {
+            CliName: `resetsetting`, 
+            KodiName: `Settings.ResetSettingValue`, 
+            Description: `Resets a setting to its default value.`,
+            ParametersDescription: map[string]string {
+                `id`: `The id of the setting, e.g. "videoplayer.autoplaynextitem".`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help resetsetting" for usage information.`)
+                }
+                return map[string]interface{} {
+                    `setting`:params[0],
+                }, nil
+            },
+        },
+        `raw`: &Command {
+            CliName: `raw`, 
+            KodiName: ``, 
+            Description: `Sends any method to Kodi and displays the response.`,
+            ParametersDescription: map[string]string {
+                `method`: `The JSONRPC method, e.g. "Player.GetProperties". Parameters are passed with --param-json.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                if len(params) < 1 {
+                    return ``, errors.New(`Not enough parameters. See "help raw" for usage information.`)
+                }
+                result, err := callMethod(config, params[0], config.RawParams)
+                if err != nil {
+                    return ``, err
+                }
+                return formatJson(result)
+            },
+        },
+        `findsong`: &Command {
+            CliName: `findsong`, 
+            KodiName: `AudioLibrary.GetSongs`, 
+            Description: `Searches the music library for songs by title.`,
+            ParametersDescription: map[string]string {
+                `query`: `Part of the title of the song.`,
+                `--play id`: `Plays the song with the given id instead of searching.`,
+                `--select[=queue]`: `Lists the songs numbered and plays or queues the chosen one.`,
+            },
+            Flags: []string{`--play`, `--select`},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                selectMode, params, err := getSelectMode(params)
+                if err != nil {
+                    return ``, err
+                }
+                if len(params) < 1 {
+                    return ``, errors.New(`Not enough parameters. See "help findsong" for usage information.`)
+                }
+                if params[0] == `--play` {
+                    if len(params) < 2 {
+                        return ``, errors.New(`Not enough parameters. See "help findsong" for usage information.`)
+                    }
+                    songID, err := strconv.Atoi(params[1])
+                    if err != nil {
+                        return ``, errors.New(`Illegal song id ` + params[1] + `.`)
+                    }
+                    _, err = callMethod(config, `Player.Open`, map[string]interface{} {
+                        `item`: map[string]interface{} {
+                            `songid`: songID,
+                        },
+                    })
+                    return ``, err
+                }
+                songs, err := findSongs(config, strings.Join(params, ` `))
+                if err != nil {
+                    return ``, err
+                }
+                if len(selectMode) > 0 {
+                    return selectItem(config, selectMode, createSongItems(songs))
+                }
+                return formatListing(config, songs, []string{`ID`, `Title`, `Artist`, `Album`}, createSongRows(songs), formatSongs(songs))
+            },
+        },
+        `refresh`: &Command {
+            CliName: `refresh`, 
+            KodiName: `VideoLibrary.RefreshMovie`, 
+            Description: `Scrapes the metadata of a single library item again.`,
+            ParametersDescription: map[string]string {
+                `type`: `The type of the item: ` + strings.Join(videoItemTypeNames(), `, `) + `.`,
+                `id`: `The library id of the item.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                if len(params) < 2 {
+                    return ``, errors.New(`Not enough parameters. See "help refresh" for usage information.`)
+                }
+                methodName, paramMap, err := createVideoItemParams(params[0], params[1])
+                if err != nil {
+                    return ``, err
+                }
+                _, err = callMethod(config, `VideoLibrary.Refresh` + methodName, paramMap)
+                return ``, err
+            },
+        },
+        `remove`: &Command {
+            CliName: `remove`, 
+            KodiName: `VideoLibrary.RemoveMovie`, 
+            Description: `Removes a single item from the video library. The files are kept.`,
+            Destructive: true,
+            ParametersDescription: map[string]string {
+                `type`: `The type of the item: ` + strings.Join(videoItemTypeNames(), `, `) + `.`,
+                `id`: `The library id of the item.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                if len(params) < 2 {
+                    return ``, errors.New(`Not enough parameters. See "help remove" for usage information.`)
+                }
+                methodName, paramMap, err := createVideoItemParams(params[0], params[1])
+                if err != nil {
+                    return ``, err
+                }
+                _, err = callMethod(config, `VideoLibrary.Remove` + methodName, paramMap)
+                return ``, err
+            },
+        },
+        `details`: &Command {
+            CliName: `details`, 
+            KodiName: `VideoLibrary.GetMovieDetails`, 
+            Description: `Displays the details of a single library item like its plot, rating and cast.`,
+            ParametersDescription: map[string]string {
+                `type`: `The type of the item: ` + strings.Join(videoItemTypeNames(), `, `) + `.`,
+                `id`: `The library id of the item.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                if len(params) < 2 {
+                    return ``, errors.New(`Not enough parameters. See "help details" for usage information.`)
+                }
+                return getVideoDetails(config, params[0], params[1])
+            },
+        },
+        `recent`: &Command {
+            CliName: `recent`, 
+            KodiName: `VideoLibrary.GetRecentlyAddedMovies`, 
+            Description: `Lists the recently added movies or episodes.`,
+            ParametersDescription: map[string]string {
+                `type`: `movie or episode.`,
+                `count`: `(optional) The maximum number of items. Defaults to ` + strconv.Itoa(defaultRecentCount) + `.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                if len(params) < 1 {
+                    return ``, errors.New(`Not enough parameters. See "help recent" for usage information.`)
+                }
+                count := defaultRecentCount
+                if len(params) > 1 {
+                    var err error
+                    if count, err = strconv.Atoi(params[1]); err != nil || count < 1 {
+                        return ``, errors.New(`Illegal count ` + params[1] + `.`)
+                    }
+                }
+                if params[0] == `movie` {
+                    return getRecentlyAddedMovies(config, count)
+                } else if params[0] == `episode` {
+                    return getRecentlyAddedEpisodes(config, count)
+                }
+                return ``, errors.New(`Unknown type ` + params[0] + `, use movie or episode.`)
+            },
+        },
+        `recentalbums`: &Command {
+            CliName: `recentalbums`, 
+            KodiName: `AudioLibrary.GetRecentlyAddedAlbums`, 
+            Description: `Lists the recently added albums.`,
+            ParametersDescription: map[string]string {
+                `count`: `(optional) The maximum number of albums. Defaults to ` + strconv.Itoa(defaultRecentCount) + `.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                count := defaultRecentCount
+                if len(params) > 0 {
+                    var err error
+                    if count, err = strconv.Atoi(params[0]); err != nil || count < 1 {
+                        return ``, errors.New(`Illegal count ` + params[0] + `.`)
+                    }
+                }
+                return getRecentlyAddedAlbums(config, count)
+            },
+        },
+        `clean`: &Command {
+            CliName: `clean`, 
+            KodiName: `VideoLibrary.Clean`, 
+            Description: `Cleans the video library from non-existent items.`,
+            LongRunning: true,
+            Destructive: true,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{}{}, nil
+            },
+        },
+        `update`: &Command {
+            CliName: `update`, 
+            KodiName: `VideoLibrary.Scan`, 
+            Description: `Scans the video sources for new library items.`,
+            LongRunning: true,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{}{}, nil
+            },
+        },
+        `exportvideo`: &Command {
+            CliName: `exportvideo`, 
+            KodiName: `VideoLibrary.Export`, 
+            Description: `Exports the video library into a single file inside a directory.`,
+            LongRunning: true,
+            ParametersDescription: map[string]string {
+                `path`: `The directory the export is written to.`,
+                `options`: `(optional) "images:true,overwrite:true" to export images and overwrite existing files.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createExportParams(params, `exportvideo`)
+            },
+        },
+        `exportaudio`: &Command {
+            CliName: `exportaudio`, 
+            KodiName: `AudioLibrary.Export`, 
+            Description: `Exports the music library into a single file inside a directory.`,
+            LongRunning: true,
+            ParametersDescription: map[string]string {
+                `path`: `The directory the export is written to.`,
+                `options`: `(optional) "images:true,overwrite:true" to export images and overwrite existing files.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createExportParams(params, `exportaudio`)
+            },
+        },
+        
+        // Files
+        `sources`: &Command {
+            CliName: `sources`, 
+            KodiName: `Files.GetSources`, 
+            Description: `Lists the configured media sources with their paths, e.g. to find a path for browse.`,
+            ParametersDescription: map[string]string {
+                `media`: `One of ` + strings.Join(mediaTypes, `, `) + `.`,
+            },
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                if len(params) < 1 {
+                    return ``, errors.New(`Not enough parameters. See "help sources" for usage information.`)
+                }
+                return getSources(config, params[0])
+            },
+        },
+        `browse`: &Command {
+            CliName: `browse`, 
+            KodiName: `Files.GetDirectory`, 
+            Description: `Lists the contents of a directory.`,
+            ParametersDescription: map[string]string {
+                `media`: `One of ` + strings.Join(mediaTypes, `, `) + `. Only files of this type are listed.`,
+                `path`: `The path of the directory, e.g. "/music/Album" or "smb://nas/music/Album".`,
+                `--select[=queue]`: `Lists the entries numbered and plays or queues the chosen one.`,
+            },
+            Flags: []string{`--select`},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                selectMode, params, err := getSelectMode(params)
+                if err != nil {
+                    return ``, err
+                }
+                paramMap, err := createDirectoryParams(params)
+                if err != nil {
+                    return ``, err
+                }
+                result, err := callMethod(config, `Files.GetDirectory`, paramMap)
+                if err != nil {
+                    return ``, err
+                }
+                files, err := parseDirectory(result)
+                if err != nil {
+                    return ``, err
+                }
+                if len(selectMode) > 0 {
+                    return selectItem(config, selectMode, createFileItems(files))
+                }
+                return formatListing(config, files, []string{`Title`, `Type`, `Path`}, createFileRows(files), formatDirectory(files))
+            },
+        },
+        
+        // Playlist
+        `insert`: &Command {
+            CliName: `insert`, 
+            KodiName: `Playlist.Insert`, 
+            Description: `Inserts a file or URL at a position of a playlist, e.g. to play it next.`,
+            ParametersDescription: map[string]string {
+                `playlist`: `One of audio, video or picture.`,
+                `position`: `The position the item is inserted at, the first item has the position 0.`,
+                `file`: `The path or URL of the file.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 3 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help insert" for usage information.`)
+                }
+                playlist, err := getPlaylist(params[0])
+                if err != nil {
+                    return map[string]interface{}{}, err
+                }
+                position, err := parsePosition(params[1], `insert`)
+                if err != nil {
+                    return map[string]interface{}{}, err
+                }
+                return map[string]interface{} {
+                    `playlistid`:playlist.ID,
+                    `position`:position,
+                    `item`:map[string]interface{} {
+                        `file`:strings.Join(params[2:], ` `),
+                    },
+                }, nil
+            },
+        },
+        `playlistremove`: &Command {
+            CliName: `playlistremove`, 
+            KodiName: `Playlist.Remove`, 
+            Description: `Removes the item at a position from a playlist.`,
+            ParametersDescription: map[string]string {
+                `playlist`: `One of audio, video or picture.`,
+                `position`: `The position of the item, the first item has the position 0.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 2 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help playlistremove" for usage information.`)
+                }
+                playlist, err := getPlaylist(params[0])
+                if err != nil {
+                    return map[string]interface{}{}, err
+                }
+                position, err := parsePosition(params[1], `playlistremove`)
+                if err != nil {
+                    return map[string]interface{}{}, err
+                }
+                return map[string]interface{} {
+                    `playlistid`:playlist.ID,
+                    `position`:position,
+                }, nil
+            },
+        },
+        `playlistswap`: &Command {
+            CliName: `playlistswap`, 
+            KodiName: `Playlist.Swap`, 
+            Description: `Swaps the items at two positions of a playlist.`,
+            ParametersDescription: map[string]string {
+                `playlist`: `One of audio, video or picture.`,
+                `position1`: `The position of the first item, the first item has the position 0.`,
+                `position2`: `The position of the second item.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 3 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help playlistswap" for usage information.`)
+                }
+                playlist, err := getPlaylist(params[0])
+                if err != nil {
+                    return map[string]interface{}{}, err
+                }
+                position1, err := parsePosition(params[1], `playlistswap`)
+                if err != nil {
+                    return map[string]interface{}{}, err
+                }
+                position2, err := parsePosition(params[2], `playlistswap`)
+                if err != nil {
+                    return map[string]interface{}{}, err
+                }
+                return map[string]interface{} {
+                    `playlistid`:playlist.ID,
+                    `position1`:position1,
+                    `position2`:position2,
+                }, nil
+            },
+        },
+        `queuedir`: &Command {
+            CliName: `queuedir`, 
+            KodiName: `Playlist.Add`, 
+            Description: `Adds all playable files of a directory and its subdirectories to a playlist.`,
+            ParametersDescription: map[string]string {
+                `playlist`: `One of audio, video or picture.`,
+                `path`: `The path of the directory, e.g. "/music/Album" or "smb://nas/music/Album".`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 2 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help queuedir" for usage information.`)
+                }
+                playlist, err := getPlaylist(params[0])
+                if err != nil {
+                    return map[string]interface{}{}, err
+                }
+                return map[string]interface{} {
+                    `playlistid`:playlist.ID,
+                    `item`:map[string]interface{} {
+                        `directory`:strings.Join(params[1:], ` `),
+                        `media`:playlist.Media,
+                        `recursive`:true,
+                    },
+                }, nil
+            },
+        },
+        
+        // Windows
+        `movies`: &Command {
+            CliName: `movies`, 
+            KodiName: `GUI.ActivateWindow`, 
+            Description: `Opens the movies of the video library.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createWindowParams(`videos`, `videodb://movies/titles/`), nil
+            },
+        },
+        `tvshows`: &Command {
+            CliName: `tvshows`, 
+            KodiName: `GUI.ActivateWindow`, 
+            Description: `Opens the TV shows of the video library.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createWindowParams(`videos`, `videodb://tvshows/titles/`), nil
+            },
+        },
+        `music`: &Command {
+            CliName: `music`, 
+            KodiName: `GUI.ActivateWindow`, 
+            Description: `Opens the music library.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createWindowParams(`music`, ``), nil
+            },
+        },
+        `pictures`: &Command {
+            CliName: `pictures`, 
+            KodiName: `GUI.ActivateWindow`, 
+            Description: `Opens the pictures.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createWindowParams(`pictures`, ``), nil
+            },
+        },
+        `settings`: &Command {
+            CliName: `settings`, 
+            KodiName: `GUI.ActivateWindow`, 
+            Description: `Opens the settings or lists the sections, categories and ids of the settings.`,
+            ParametersDescription: map[string]string {
+                `sections`: `Lists the sections of the settings.`,
+                `categories section`: `Lists the categories of a section.`,
+                `list section category`: `Lists the ids of the settings of a category, e.g. for getsetting.`,
+            },
+            Subcommands: map[string]*Command {
+                `sections`: &Command {
+                    CliName: `sections`, 
+                    KodiName: `Settings.GetSections`, 
+                    Description: `Lists the sections of the settings.`,
+                    ParametersDescription: map[string]string {},
+                    CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                        return map[string]interface{} {
+                            `level`:settingsLevel,
+                        }, nil
+                    },
+                    FormatResult: formatSections,
+                },
+                `categories`: &Command {
+                    CliName: `categories`, 
+                    KodiName: `Settings.GetCategories`, 
+                    Description: `Lists the categories of a section.`,
+                    ParametersDescription: map[string]string {
+                        `section`: `The id of the section, e.g. "system".`,
+                    },
+                    CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                        if len(params) < 1 {
+                            return map[string]interface{}{}, errors.New(`Not enough parameters. See "help settings" for usage information.`)
+                        }
+                        return map[string]interface{} {
+                            `section`:params[0],
+                            `level`:settingsLevel,
+                        }, nil
+                    },
+                    FormatResult: formatCategories,
+                },
+                `list`: &Command {
+                    CliName: `list`, 
+                    KodiName: `Settings.GetSettings`, 
+                    Description: `Lists the settings of a category.`,
+                    ParametersDescription: map[string]string {
+                        `section`: `The id of the section, e.g. "system".`,
+                        `category`: `The id of the category, e.g. "display".`,
+                    },
+                    CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                        if len(params) < 2 {
+                            return map[string]interface{}{}, errors.New(`Not enough parameters. See "help settings" for usage information.`)
+                        }
+                        return map[string]interface{} {
+                            `level`:settingsLevel,
+                            `filter`:map[string]interface{} {
+                                `section`:params[0],
+                                `category`:params[1],
+                            },
+                        }, nil
+                    },
+                    FormatResult: formatSettings,
+                },
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createWindowParams(`settings`, ``), nil
+            },
+        },
+        `weather`: &Command {
+            CliName: `weather`, 
+            KodiName: `GUI.ActivateWindow`, 
+            Description: `Opens the weather forecast.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createWindowParams(`weather`, ``), nil
+            },
+        },
+        `addons`: &Command {
+            CliName: `addons`, 
+            KodiName: `GUI.ActivateWindow`, 
+            Description: `Opens the add-on browser or lists the installed addons.`,
+            ParametersDescription: map[string]string {
+                `list type`: `Lists the ids and names of the installed addons, optionally only those of a type, e.g. "xbmc.python.pluginsource".`,
+            },
+            Subcommands: map[string]*Command {
+                `list`: &Command {
+                    CliName: `list`, 
+                    KodiName: `Addons.GetAddons`, 
+                    Description: `Lists the installed addons.`,
+                    ParametersDescription: map[string]string {
+                        `type`: `(optional) The type of the addons, e.g. "xbmc.python.pluginsource".`,
+                    },
+                    Execute: func(config administration.Configuration, params []string) (string, error) {
+                        addonType := ``
+                        if len(params) > 0 {
+                            addonType = params[0]
+                        }
+                        return getAddons(config, addonType)
+                    },
+                },
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return createWindowParams(`addonbrowser`, ``), nil
+            },
+        },
+        
+        // System
+        `eject`: &Command {
+            CliName: `eject`, 
+            KodiName: `System.EjectOpticalDrive`, 
+            Description: `Ejects or closes the tray of the optical drive.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{}{}, nil
+            },
+        },
+        `shutdown`: &Command {
+            CliName: `shutdown`, 
+            KodiName: `System.Shutdown`, 
+            Description: `Shuts the system down. Refuses if the system does not support it.`,
+            ParametersDescription: map[string]string {},
+            Destructive: true,
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return executePowerMethod(config, `System.Shutdown`)
+            },
+        },
+        `reboot`: &Command {
+            CliName: `reboot`, 
+            KodiName: `System.Reboot`, 
+            Description: `Reboots the system. Refuses if the system does not support it.`,
+            ParametersDescription: map[string]string {},
+            Destructive: true,
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return executePowerMethod(config, `System.Reboot`)
+            },
+        },
+        `suspend`: &Command {
+            CliName: `suspend`, 
+            KodiName: `System.Suspend`, 
+            Description: `Suspends the system. Refuses if the system does not support it.`,
+            ParametersDescription: map[string]string {},
+            Destructive: true,
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return executePowerMethod(config, `System.Suspend`)
+            },
+        },
+        `hibernate`: &Command {
+            CliName: `hibernate`, 
+            KodiName: `System.Hibernate`, 
+            Description: `Hibernates the system. Refuses if the system does not support it.`,
+            ParametersDescription: map[string]string {},
+            Destructive: true,
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return executePowerMethod(config, `System.Hibernate`)
+            },
+        },
+        
+        // Addons
+        `launch`: &Command {
+            CliName: `launch`, 
+            KodiName: `GUI.ActivateWindow`, 
+            Description: `Opens a path of an addon, e.g. "plugin://plugin.video.youtube/".`,
+            ParametersDescription: map[string]string {
+                `path`: `The plugin path. Video, audio and image plugins are opened in videos, music and pictures, all others in programs.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help launch" for usage information.`)
+                }
+                if !strings.HasPrefix(params[0], pluginPrefix) {
+                    return map[string]interface{}{}, errors.New(`Illegal path ` + params[0] + `, it needs to start with ` + pluginPrefix + `.`)
+                }
+                return createWindowParams(getPluginWindow(params[0]), params[0]), nil
+            },
+        },
+        `addonenable`: &Command {
+            CliName: `addonenable`, 
+            KodiName: `Addons.SetAddonEnabled`, 
+            Description: `Enables an addon.`,
+            ParametersDescription: map[string]string {
+                `addonid`: `The id of the addon, e.g. "plugin.video.youtube".`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help addonenable" for usage information.`)
+                }
+                return map[string]interface{} {
+                    `addonid`:params[0],
+                    `enabled`:true,
+                }, nil
+            },
+        },
+        `addondisable`: &Command {
+            CliName: `addondisable`, 
+            KodiName: `Addons.SetAddonEnabled`, 
+            Description: `Disables an addon.`,
+            ParametersDescription: map[string]string {
+                `addonid`: `The id of the addon, e.g. "plugin.video.youtube".`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                if len(params) < 1 {
+                    return map[string]interface{}{}, errors.New(`Not enough parameters. See "help addondisable" for usage information.`)
+                }
+                return map[string]interface{} {
+                    `addonid`:params[0],
+                    `enabled`:false,
+                }, nil
+            },
+        },
+        
+        // PVR
+        `record`: &Command {
+            CliName: `record`, 
+            KodiName: `PVR.Record`, 
+            Description: `Starts or stops recording the current channel.`,
+            ParametersDescription: map[string]string {},
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `record`:`toggle`,
+                    `channel`:`current`,
+                }, nil
+            },
+        },
+        `channelup`: &Command {
+            CliName: `channelup`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Switches to the next channel.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of channels to switch.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`channelup`,
+                }, nil
+            },
+        },
+        `channeldown`: &Command {
+            CliName: `channeldown`, 
+            KodiName: `Input.ExecuteAction`, 
+            Description: `Switches to the previous channel.`,
+            ParametersDescription: map[string]string {
+                `n`: `(optional) The number of channels to switch.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                return map[string]interface{} {
+                    `action`:`channeldown`,
+                }, nil
+            },
+        },
+        `channels`: &Command {
+            CliName: `channels`, 
+            KodiName: `PVR.GetChannels`, 
+            Description: `Lists all channels.`,
+            ParametersDescription: map[string]string {
+                `tv/radio`: `(optional) List the TV or the radio channels. Defaults to tv.`,
+            },
+            CreateParameterMap: func(params []string) (map[string]interface{}, error) {
+                channelGroup := `alltv`
+                if len(params) > 0 {
+                    if params[0] == `radio` {
+                        channelGroup = `allradio`
+                    } else if params[0] != `tv` {
+                        return map[string]interface{}{}, errors.New(`Illegal parameter. See "help channels" for usage information.`)
+                    }
+                }
+                return map[string]interface{} {
+                    `channelgroupid`:channelGroup,
+                }, nil
+            },
+            FormatResult: formatChannels,
+        },
+        
+        // Notifications
+        `watch`: &Command {
+            CliName: `watch`, 
+            KodiName: ``, 
+            Description: `Prints the notifications of Kodi, e.g. Player.OnPlay, until Ctrl-C is pressed.`,
+            ParametersDescription: map[string]string {},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                return ``, subscribeNotifications(config, func(notification Notification) error {
+                    fmt.Println(formatNotification(notification))
+                    return nil
+                })
+            },
+        },
+        `daemon`: &Command {
+            CliName: `daemon`, 
+            KodiName: ``, 
+            Description: `Exposes the playback state as Prometheus metrics until Ctrl-C is pressed.`,
+            ParametersDescription: map[string]string {
+                `--metrics=address`: `(optional) The address serving /metrics. Defaults to ` + defaultMetricsAddress + `.`,
+            },
+            Flags: []string{`--metrics`},
+            Execute: func(config administration.Configuration, params []string) (string, error) {
+                address := defaultMetricsAddress
+                for i, param := range params {
+                    if strings.HasPrefix(param, `--metrics=`) {
+                        address = strings.TrimPrefix(param, `--metrics=`)
+                    } else if param == `--metrics` && i < len(params) - 1 {
+                        address = params[i + 1]
+                    }
+                }
+                return ``, runDaemon(config, address)
+            },
+        },
+    }
+)
+
+// createPlusMinusAction creates the parameters for Input.ExecuteAction
+// choosing the plus or the minus action depending on the first parameter.
+func createPlusMinusAction(params []string, cliName, plusAction, minusAction string) (map[string]interface{}, error) {
+    if len(params) < 1 {
+        return map[string]interface{}{}, errors.New(`Not enough parameters. See "help ` + cliName + `" for usage information.`)
+    }
+    var action string
+    if params[0] == `+` {
+        action = plusAction
+    } else if params[0] == `-` {
+        action = minusAction
+    } else {
+        return map[string]interface{}{}, errors.New(`Illegal parameter. See "help ` + cliName + `" for usage information.`)
+    }
+    return map[string]interface{} {
+        `action`:action,
+    }, nil
+}
+
+// createWindowParams creates the params of GUI.ActivateWindow opening the
+// window and, if it is not empty, the path inside of it.
+func createWindowParams(window, path string) map[string]interface{} {
+    params := map[string]interface{} {
+        `window`:window,
+    }
+    if len(path) > 0 {
+        params[`parameters`] = []string{path}
+    }
+    return params
+}
+
+// getPluginWindow returns the window a plugin path is opened in
+// depending on the type of the plugin, e.g. "videos" for plugin.video.youtube.
+func getPluginWindow(path string) string {
+    addonID := strings.SplitN(strings.TrimPrefix(path, pluginPrefix), `/`, 2)[0]
+    for prefix, window := range pluginWindows {
+        if strings.HasPrefix(addonID, prefix) {
+            return window
+        }
+    }
+    return `programs`
+}
+
+// createExportParams creates the params of a library export into the
+// directory given as first parameter. The remaining parameters may enable
+// exporting images and overwriting files.
+func createExportParams(params []string, cliName string) (map[string]interface{}, error) {
+    if len(params) < 1 {
+        return map[string]interface{}{}, errors.New(`Not enough parameters. See "help ` + cliName + `" for usage information.`)
+    }
+    options := map[string]interface{} {
+        `path`:params[0],
+    }
+    for key, value := range parseKeyValueParams(params[1:]) {
+        if key != `images` && key != `overwrite` {
+            return map[string]interface{}{}, errors.New(`Unknown option ` + key + `. See "help ` + cliName + `" for usage information.`)
+        }
+        options[key] = value == `true`
+    }
+    return map[string]interface{} {
+        `options`:options,
+    }, nil
+}
+
+// parseKeyValueParams parses params entered like "key1:value,key2:value".
+func parseKeyValueParams(params []string) map[string]string {
+    keyValues := map[string]string{}
+    for _, pair := range strings.Split(strings.Join(params, ` `), `,`) {
+        keyValue := strings.SplitN(pair, `:`, 2)
+        if len(keyValue) == 2 {
+            keyValues[strings.TrimSpace(keyValue[0])] = keyValue[1]
+        }
+    }
+    return keyValues
+}
+
+// parseMilliseconds parses a duration like "5s" or "1500ms" and returns
+// it in milliseconds. A plain number is already treated as milliseconds.
+func parseMilliseconds(value string) (int, error) {
+    if milliseconds, err := strconv.Atoi(value); err == nil {
+        return milliseconds, nil
+    }
+    duration, err := time.ParseDuration(value)
+    if err != nil {
+        return 0, errors.New(`Illegal duration ` + value + `, use e.g. "5s" or "1500ms".`)
+    }
+    return int(duration / time.Millisecond), nil
+}
+
+// parseTimeNumber parses a number and makes sure that
+// the number is >= 0 and <= 59
+func parseTimeNumber(number string) (int, error) {
+    num, err := strconv.Atoi(number)
+    if err != nil {
+        return 0, err
+    } else if num > 59 || num < 0 {
+        return 0, errors.New(`A time-number needs to be between 0 and 59, but was ` + number)
+    } else {
+        return num, nil
+    }
+}
+
+// CoerceValue converts the value entered on the command line into
+// a boolean or a number if possible, otherwise the string is kept. NaN and
+// infinity stay strings because they can't be sent as JSON.
+func CoerceValue(value string) interface{} {
+    if value == `true` || value == `false` {
+        return value == `true`
+    }
+    if number, err := strconv.Atoi(value); err == nil {
+        return number
+    }
+    if number, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+        return number
+    }
+    return value
+}
+
+// formatValue creates a readable representation of a value returned
+// by Kodi. Strings are returned as they are, everything else as JSON.
+func formatValue(value interface{}) (string, error) {
+    if str, ok := value.(string); ok {
+        return str, nil
+    }
+    output, err := json.Marshal(value)
+    return string(output), err
+}
+
+// formatJson indents the JSON returned by Kodi to make it readable.
+func formatJson(result json.RawMessage) (string, error) {
+    var output bytes.Buffer
+    err := json.Indent(&output, result, ``, `    `)
+    return output.String(), err
+}
+
+// clampVolume makes sure that the volume is >= 0 and <= 100.
+func clampVolume(volume int) int {
+    if volume < 0 {
+        return 0
+    } else if volume > 100 {
+        return 100
+    }
+    return volume
+}
+
+// getVolume asks Kodi for the current volume.
+func getVolume(config administration.Configuration) (int, error) {
+    var properties struct {
+        Volume int `json:"volume"`
+    }
+    result, err := callMethod(config, `Application.GetProperties`, map[string]interface{} {
+        `properties`: []string{`volume`},
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &properties)
+    }
+    return properties.Volume, err
+}
+
+// parseMillisecondFraction parses the digits following the decimal point
+// of the seconds, e.g. "5" or "500", and returns them as milliseconds.
+func parseMillisecondFraction(fraction string) (int, error) {
+    if len(fraction) == 0 || len(fraction) > 3 {
+        return 0, errors.New(`The fraction of a second needs to have between 1 and 3 digits, but was ` + fraction)
+    }
+    for _, digit := range fraction {
+        if digit < '0' || digit > '9' {
+            return 0, errors.New(`The fraction of a second may only contain digits, but was ` + fraction)
+        }
+    }
+    milliseconds, _ := strconv.Atoi(fraction + strings.Repeat(`0`, 3 - len(fraction)))
+    return milliseconds, nil
+}
+
+// fadeVolume changes the volume to target in small steps spread
+// over the duration.
+func fadeVolume(config administration.Configuration, target int, duration time.Duration) error {
+    start, err := getVolume(config)
+    if err != nil {
+        return err
+    }
+    steps := int(duration / fadeStepInterval)
+    if steps < 1 {
+        steps = 1
+    }
+    lastVolume := start
+    for step := 1; step <= steps; step++ {
+        time.Sleep(fadeStepInterval)
+        volume := start + (target - start) * step / steps
+        if volume == lastVolume {
+            continue
+        }
+        if _, err := callMethod(config, `Application.SetVolume`, map[string]interface{} {
+            `volume`: volume,
+        }); err != nil {
+            return err
+        }
+        lastVolume = volume
+    }
+    return nil
+}
+
+// changeVolume calls change, if silent is set the configured setting
+// showing the volume bar is turned off before and restored afterwards.
+func changeVolume(config administration.Configuration, silent bool, change func() error) error {
+    if !silent {
+        return change()
+    }
+    if len(config.VolumeOSDSetting) == 0 {
+        return errors.New(`No setting for the volume bar configured. See "help" to learn about --volume-osd-setting.`)
+    }
+    var setting struct {
+        Value interface{} `json:"value"`
+    }
+    result, err := callMethod(config, `Settings.GetSettingValue`, map[string]interface{} {
+        `setting`: config.VolumeOSDSetting,
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &setting)
+    }
+    if err != nil {
+        return err
+    }
+    if _, err := callMethod(config, `Settings.SetSettingValue`, map[string]interface{} {
+        `setting`: config.VolumeOSDSetting,
+        `value`: false,
+    }); err != nil {
+        return err
+    }
+    err = change()
+    if _, restoreErr := callMethod(config, `Settings.SetSettingValue`, map[string]interface{} {
+        `setting`: config.VolumeOSDSetting,
+        `value`: setting.Value,
+    }); err == nil {
+        err = restoreErr
+    }
+    return err
+}
+
+// removeFlag returns params without flag and whether flag was part of them.
+func removeFlag(params []string, flag string) ([]string, bool) {
+    remaining := []string{}
+    found := false
+    for _, param := range params {
+        if param == flag {
+            found = true
+        } else {
+            remaining = append(remaining, param)
+        }
+    }
+    return remaining, found
+}
+
+// GetCommandForName returns a copy of the Command related to the CliName passed
+// if it exists. A unique prefix of the CliName is accepted as well.
+func GetCommandForName(cmd string) (Command, bool) {
+    name, err := resolveCommandName(cmd)
+    if err != nil {
+        return Command{}, false
+    }
+    return *CommandMap[name], true 
+}
+
+// IsCommandFlag returns true if the command in args accepts the flag as a
+// parameter. The command is the first argument which is no flag, the
+// second one selects a subcommand if the command has one of that name.
+func IsCommandFlag(args []string, flag string) bool {
+    names := []string{}
+    for _, arg := range args {
+        if !strings.HasPrefix(arg, `--`) {
+            names = append(names, arg)
+        }
+    }
+    if len(names) == 0 {
+        return false
+    }
+    action, err := resolveCommandName(names[0])
+    if err != nil {
+        return false
+    }
+    command := CommandMap[action]
+    if len(names) > 1 {
+        if subcommand, success := command.Subcommands[names[1]]; success {
+            command = subcommand
+        }
+    }
+    for _, commandFlag := range command.Flags {
+        if commandFlag == flag {
+            return true
+        }
+    }
+    return false
+}
+
+// resolveCommandName returns the CliName of the command which is either
+// named exactly like the passed name or the only one starting with it.
+func resolveCommandName(name string) (string, error) {
+    if _, success := CommandMap[name]; success {
+        return name, nil
+    }
+    candidates := []string{}
+    for cliName := range CommandMap {
+        if strings.HasPrefix(cliName, name) {
+            candidates = append(candidates, cliName)
+        }
+    }
+    if len(candidates) == 1 {
+        return candidates[0], nil
+    } else if len(candidates) > 1 {
+        sort.Strings(candidates)
+        return ``, errors.New(`The Command ` + name + ` is ambiguous: ` + strings.Join(candidates, `, `))
+    }
+    message := "The Command " + name + " is unknown."
+    if suggestions := suggestCommandNames(name); len(suggestions) > 0 {
+        message += ` Did you mean: ` + strings.Join(suggestions, `, `) + `?`
+    }
+    return ``, errors.New(message)
+}
+
+// suggestCommandNames returns the CliNames closest to the passed name
+// whose distance does not exceed maxSuggestionDistance. Names which would
+// need to be replaced completely are never suggested.
+func suggestCommandNames(name string) []string {
+    suggestions := []string{}
+    distances := map[string]int{}
+    for cliName := range CommandMap {
+        if distance := levenshteinDistance(name, cliName); distance <= maxSuggestionDistance && distance < len(name) {
+            suggestions = append(suggestions, cliName)
+            distances[cliName] = distance
+        }
+    }
+    sort.Slice(suggestions, func(i, j int) bool {
+        if distances[suggestions[i]] != distances[suggestions[j]] {
+            return distances[suggestions[i]] < distances[suggestions[j]]
+        }
+        return suggestions[i] < suggestions[j]
+    })
+    if len(suggestions) > maxSuggestions {
+        suggestions = suggestions[:maxSuggestions]
+    }
+    return suggestions
+}
+
+// levenshteinDistance returns the number of single character edits
+// needed to turn a into b.
+func levenshteinDistance(a, b string) int {
+    previous := make([]int, len(b) + 1)
+    current := make([]int, len(b) + 1)
+    for j := range previous {
+        previous[j] = j
+    }
+    for i := 1; i <= len(a); i++ {
+        current[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i - 1] == b[j - 1] {
+                cost = 0
+            }
+            current[j] = min(previous[j] + 1, current[j - 1] + 1, previous[j - 1] + cost)
+        }
+        previous, current = current, previous
+    }
+    return previous[len(b)]
+}
+
+// pluginPrefix is the scheme of paths provided by addons.
+const pluginPrefix = `plugin://`
+
+// pluginWindows maps the prefixes of addon ids to the window
+// their plugin paths are opened in.
+var pluginWindows = map[string]string {
+    `plugin.video.`: `videos`,
+    `plugin.audio.`: `music`,
+    `plugin.image.`: `pictures`,
+}
+
+// repeatableActions contains all actions which accept the number of
+// repetitions as their last parameter.
+var repeatableActions = map[string]bool {
+    `down`: true,
+    `up`: true,
+    `left`: true,
+    `right`: true,
+    `subdelay`: true,
+    `audiodelay`: true,
+    `ff`: true,
+    `rw`: true,
+    `stepfwd`: true,
+    `stepback`: true,
+    `bigfwd`: true,
+    `bigback`: true,
+    `pan`: true,
+    `osdvolup`: true,
+    `osdvoldown`: true,
+    `channelup`: true,
+    `channeldown`: true,
+}
+
+// getRepeatCount returns for some allowed actions the number how often this action
+// should be executed.
+func getRepeatCount(action string, params *[]string) int {
+    if len(*params) > 0 && repeatableActions[action] {
+        num, err := strconv.Atoi((*params)[len(*params) - 1])
+        if err != nil || num < 1 {
+            return 1
+        }
+        *params = (*params)[:len(*params) - 1]
+        return num
+    }
+    return 1
+}
+
+// ExecuteCommand takes the action, looks up the appropriate JSON-RPC command
+// and sends the request to the configured address. The returned string
+// contains the output for the user, if the command has any.
+// If expectations are configured they are checked against the response
+// of the last request the command sent.
+func ExecuteCommand(config administration.Configuration, action string, params []string) (string, error) {
+    lastResult = nil
+    output, err := executeCommand(config, action, params)
+    if err == nil && len(config.Expectations) > 0 {
+        err = checkExpectations(config.Expectations, lastResult)
+    }
+    return output, err
+}
+
+// executeCommand executes the command without checking expectations.
+func executeCommand(config administration.Configuration, action string, params []string) (string, error) {
+    action, err := resolveCommandName(action)
+    if err != nil {
+        return ``, err
+    }
+    command := CommandMap[action]
+    if len(params) > 0 {
+        if subcommand, success := command.Subcommands[params[0]]; success {
+            command, params = subcommand, params[1:]
+        }
+    }
+    if err := confirmCommand(config, command); err != nil {
+        return ``, err
+    }
+    if command.Execute != nil {
+        return command.Execute(config, params)
+    }
     repeatCount := getRepeatCount(action, &params)
-    cmd, err := createJsonCommand(action, params)
+    cmd, err := createJsonCommand(config, command, params)
     if err == nil {
+        var result json.RawMessage
+        stopSpinner := func() {}
+        if command.LongRunning {
+            stopSpinner = startSpinner(config)
+        }
         for i := 0; i < repeatCount; i++ {
-            err = sendRequest(config.Host, config.Port, cmd)
+            result, err = sendRequestUntilSuccess(config, cmd)
         }
-        return err
+        stopSpinner()
+        invalidatePlayerIDCacheFor(command.KodiName)
+        if err == nil && !config.NoConfigWrite {
+            rememberRequest(cmd)
+        }
+        if err == nil && command.FormatResult != nil {
+            return command.FormatResult(result)
+        }
+        return ``, err
     } else {
-        return err
+        return ``, err
+    }
+}
+
+// callMethod creates the JSONRPC call for the given method and params,
+// sends it to Kodi and returns the result.
+func callMethod(config administration.Configuration, method string, params map[string]interface{}) (json.RawMessage, error) {
+    var command CommandRequest
+    adaptParamsToVersion(config, method, params)
+    command.SetValues(method, params)
+    output, err := json.Marshal(command)
+    if err != nil {
+        return nil, err
     }
+    defer invalidatePlayerIDCacheFor(method)
+    return sendRequestUntilSuccess(config, string(output))
 }
 
-// sendRequest actually sends the request to Kodi.
-func sendRequest(host, port, js string) error {
+// sendRequest sends the request to Kodi and returns the result of the call.
+// If Kodi fails to execute the method, which happens right after it was
+// started, the request is sent again up to startupRetries times.
+func sendRequest(config administration.Configuration, js string) (json.RawMessage, error) {
+    for retry := 0; ; retry++ {
+        result, err := sendRequestOnce(config, js)
+        if err == nil {
+            lastResult = result
+        }
+        jsonError, isJsonError := err.(*JsonError)
+        if !isJsonError || jsonError.Code != startupErrorCode || retry == startupRetries {
+            return result, err
+        }
+        logging.Info(`Kodi failed to execute the request, retrying in`, startupRetryDelay.String())
+        time.Sleep(startupRetryDelay)
+    }
+}
+
+// stateResultMethods return the new state instead of "OK", so a false result
+// doesn't mean that they failed.
+var stateResultMethods = map[string]bool {
+    `Application.SetMute`: true,
+    `GUI.SetFullscreen`: true,
+}
+
+// sendRequestUntilSuccess sends the request until it succeeds or the
+// configured maximum of attempts is reached. A request fails if Kodi returns
+// an error or false, unless the method returns its new state. Every request
+// of a command is sent this way. Toggle requests are sent only once since
+// repeating them would undo them. Input methods always return "OK", so a
+// dropped key press can't be detected.
+func sendRequestUntilSuccess(config administration.Configuration, js string) (json.RawMessage, error) {
+    var request CommandRequest
+    if err := json.Unmarshal([]byte(js), &request); err != nil {
+        return nil, err
+    }
+    if config.MaxAttempts == 0 || isToggleRequest(request) {
+        return sendRequest(config, js)
+    }
+    for attempt := 1; ; attempt++ {
+        result, err := sendRequest(config, js)
+        if err == nil && (string(result) != `false` || stateResultMethods[request.Method]) {
+            return result, err
+        }
+        if attempt >= config.MaxAttempts {
+            if err == nil {
+                err = errors.New(`Kodi did not report success after ` + strconv.Itoa(attempt) + ` attempts.`)
+            }
+            return result, err
+        }
+        logging.Info(`Attempt`, attempt, `of`, config.MaxAttempts, `failed, repeating the request`)
+        time.Sleep(attemptDelay)
+    }
+}
+
+// isToggleRequest returns true if one of the parameters of the request is
+// "toggle".
+func isToggleRequest(request CommandRequest) bool {
+    for _, value := range request.Params {
+        if value == `toggle` {
+            return true
+        }
+    }
+    return false
+}
+
+// sendRequestOnce actually sends the request to Kodi and returns the
+// result of the call.
+func sendRequestOnce(config administration.Configuration, js string) (json.RawMessage, error) {
 
-    requestURL := `http://` + host + `:` + port + `/jsonrpc`
+    if err := logRequest(config, js); err != nil {
+        return nil, err
+    }
+    address := createAddress(config)
+    requestURL := `http://` + address + `/jsonrpc`
+    logging.Debug(`Sending request to`, requestURL + `:`, js)
     if request, err := http.NewRequest(`POST`, requestURL, strings.NewReader(js)); err == nil {
         var header http.Header = map[string][]string{}
         header.Add(`Content-Type`, `application/json`)
         request.Header = header
-        var client http.Client
+        client, err := createHttpClient(config)
+        if err != nil {
+            return nil, err
+        }
 
         if response, err := client.Do(request); err == nil {
             defer response.Body.Close()
 
             if resp, err := ioutil.ReadAll(response.Body); err == nil {
-                
-                var errorResponse ErrorResponse
-                if err = json.Unmarshal(resp, &errorResponse); err == nil {
-                    if errorResponse.Error.Code != 0 {
-                        return createJsonError(errorResponse)
+                logging.Debug(`Received response:`, string(resp))
+                var kodiResponse Response
+                if err = json.Unmarshal(resp, &kodiResponse); err == nil {
+                    if kodiResponse.Error.Code != 0 {
+                        return nil, createJsonError(kodiResponse.ErrorResponse)
                     }
+                    return kodiResponse.Result, nil
                 } else {
-                    return err
+                    return nil, err
                 }
             } else {
-                return err
+                return nil, createNetworkError(address, err)
             }
         } else {
-            return err
+            return nil, createNetworkError(address, err)
         }
     } else {
+        return nil, err
+    }
+}
+
+// createNetworkError names the address of Kodi in an error of the connection,
+// because the plain error doesn't tell which Kodi failed if several are used.
+func createNetworkError(address string, err error) error {
+    if urlError, isURLError := err.(*url.Error); isURLError {
+        err = urlError.Err
+    }
+    if netError, isNetError := err.(net.Error); isNetError && netError.Timeout() {
+        return errors.New(`Kodi at ` + address + ` did not answer in time: ` + err.Error())
+    }
+    return errors.New(`Connection to Kodi at ` + address + ` failed: ` + err.Error())
+}
+
+// createHttpClient creates a client which uses the configured connect
+// timeout for establishing the connection and the read timeout for
+// the whole request.
+func createHttpClient(config administration.Configuration) (*http.Client, error) {
+    connectTimeout, err := config.GetConnectTimeout()
+    if err != nil {
+        return nil, err
+    }
+    readTimeout, err := config.GetReadTimeout()
+    if err != nil {
+        return nil, err
+    }
+    dialer := &net.Dialer {
+        Timeout: connectTimeout,
+    }
+    return &http.Client {
+        Timeout: readTimeout,
+        Transport: &http.Transport {
+            DialContext: dialer.DialContext,
+        },
+    }, nil
+}
+
+// createAddress joins the configured host and port. IPv6 hosts are
+// wrapped in brackets.
+func createAddress(config administration.Configuration) string {
+    return net.JoinHostPort(strings.Trim(config.Host, `[]`), config.Port)
+}
+
+// logRequest appends the time, the method and the params of the request
+// to the configured log file. Nothing is logged if no log file is configured.
+func logRequest(config administration.Configuration, js string) error {
+    if len(config.LogFile) == 0 {
+        return nil
+    }
+    var command CommandRequest
+    if err := json.Unmarshal([]byte(js), &command); err != nil {
         return err
     }
-    return nil
+    if command.Params == nil {
+        command.Params = map[string]interface{}{}
+    }
+    params, err := json.Marshal(command.Params)
+    if err != nil {
+        return err
+    }
+    logFile, err := os.OpenFile(config.LogFile, os.O_APPEND | os.O_CREATE | os.O_WRONLY, 0600)
+    if err != nil {
+        return err
+    }
+    defer logFile.Close()
+    _, err = fmt.Fprintln(logFile, time.Now().Format(time.RFC3339), command.Method, string(params))
+    return err
 }
 
 // createJsonError creates a more readable message from an ErrorResponse
@@ -413,32 +2465,41 @@ func createJsonError(errorResponse ErrorResponse) error {
     if errorResponse.Error.Data.Stack.Type != `` {
         message += `of type "` + errorResponse.Error.Data.Stack.Type + `"`
     }
-    return errors.New(message)
+    if message == `` {
+        message = errorResponse.Error.Message
+    }
+    return &JsonError{errorResponse.Error.Code, message}
 }
 
-// createJsonCommand takes the action and the params and creates a Command.
-// If the Command was created successfully the first return value will be the
-// JSON and the second nil, otherwise the first one will be nil and the second
+// createJsonCommand takes the Command and the params and creates the request.
+// If raw params are configured they are sent instead of the params created
+// by the Command itself. Otherwise the playerid is replaced by the id of
+// the active player.
+// If the request was created successfully the first return value will be the
+// JSON and the second nil, otherwise the first one will be empty and the second
 // one will be an error message.
-func createJsonCommand(action string, params []string) (string, error) {
+func createJsonCommand(config administration.Configuration, cmd *Command, params []string) (string, error) {
     var command CommandRequest
-    cmd, success := CommandMap[action]
-    
-    if success {
-        paramMap, err := cmd.CreateParameterMap(params)
-        if err != nil {
+    paramMap := config.RawParams
+    if paramMap == nil {
+        var err error
+        if paramMap, err = cmd.CreateParameterMap(params); err != nil {
             return ``, err
         }
-        command.SetValues(cmd.KodiName, paramMap)
-        output, err := json.Marshal(command)
-        
-        if err == nil {
-            return string(output), nil
-        } else {
-            return ``, err
+        if _, usesPlayer := paramMap[`playerid`]; usesPlayer {
+            if paramMap[`playerid`], err = getActivePlayerID(config); err != nil {
+                return ``, err
+            }
         }
+        adaptParamsToVersion(config, cmd.KodiName, paramMap)
+    }
+    command.SetValues(cmd.KodiName, paramMap)
+    output, err := json.Marshal(command)
+    
+    if err == nil {
+        return string(output), nil
     } else {
-        return ``, errors.New("The Command " + action + " is unknown.")
+        return ``, err
     }
 }
 
@@ -0,0 +1,321 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// videoItemTypes maps the item types of the video library to the
+// name they have inside the method names, e.g. VideoLibrary.RefreshMovie.
+var videoItemTypes = map[string]string {
+    `movie`: `Movie`,
+    `tvshow`: `TVShow`,
+    `episode`: `Episode`,
+    `musicvideo`: `MusicVideo`,
+}
+
+// Song is a song of the music library.
+type Song struct {
+    SongID int `json:"songid"`
+    Title string `json:"title"`
+    Artist []string `json:"artist"`
+    Album string `json:"album"`
+}
+
+// Album is an album of the music library.
+type Album struct {
+    AlbumID int `json:"albumid"`
+    Title string `json:"title"`
+    Artist []string `json:"artist"`
+    Year int `json:"year"`
+}
+
+// Movie is a movie of the video library.
+type Movie struct {
+    MovieID int `json:"movieid"`
+    Title string `json:"title"`
+    Year int `json:"year"`
+}
+
+// Episode is an episode of a TV show of the video library.
+type Episode struct {
+    EpisodeID int `json:"episodeid"`
+    Title string `json:"title"`
+    ShowTitle string `json:"showtitle"`
+    Season int `json:"season"`
+    Episode int `json:"episode"`
+}
+
+// getRecentlyAddedMovies lists the count movies which were added last.
+func getRecentlyAddedMovies(config administration.Configuration, count int) (string, error) {
+    var movies struct {
+        Movies []Movie `json:"movies"`
+    }
+    result, err := callMethod(config, `VideoLibrary.GetRecentlyAddedMovies`, map[string]interface{} {
+        `properties`: []string{`title`, `year`},
+        `limits`: map[string]interface{} {
+            `end`: count,
+        },
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &movies)
+    }
+    if err != nil {
+        return ``, err
+    }
+    plain := `No movies found.`
+    lines := make([]string, len(movies.Movies))
+    rows := make([][]string, len(movies.Movies))
+    for i, movie := range movies.Movies {
+        lines[i] = fmt.Sprintf(`%d - %s (%d)`, movie.MovieID, movie.Title, movie.Year)
+        rows[i] = []string{strconv.Itoa(movie.MovieID), movie.Title, strconv.Itoa(movie.Year)}
+    }
+    if len(lines) > 0 {
+        plain = strings.Join(lines, "\n")
+    }
+    return formatListing(config, movies.Movies, []string{`ID`, `Title`, `Year`}, rows, plain)
+}
+
+// getRecentlyAddedEpisodes lists the count episodes which were added last.
+func getRecentlyAddedEpisodes(config administration.Configuration, count int) (string, error) {
+    var episodes struct {
+        Episodes []Episode `json:"episodes"`
+    }
+    result, err := callMethod(config, `VideoLibrary.GetRecentlyAddedEpisodes`, map[string]interface{} {
+        `properties`: []string{`title`, `showtitle`, `season`, `episode`},
+        `limits`: map[string]interface{} {
+            `end`: count,
+        },
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &episodes)
+    }
+    if err != nil {
+        return ``, err
+    }
+    plain := `No episodes found.`
+    lines := make([]string, len(episodes.Episodes))
+    rows := make([][]string, len(episodes.Episodes))
+    for i, episode := range episodes.Episodes {
+        number := fmt.Sprintf(`S%02dE%02d`, episode.Season, episode.Episode)
+        lines[i] = fmt.Sprintf(`%d - %s %s - %s`, episode.EpisodeID, episode.ShowTitle, number, episode.Title)
+        rows[i] = []string{strconv.Itoa(episode.EpisodeID), episode.ShowTitle, number, episode.Title}
+    }
+    if len(lines) > 0 {
+        plain = strings.Join(lines, "\n")
+    }
+    return formatListing(config, episodes.Episodes, []string{`ID`, `Show`, `Episode`, `Title`}, rows, plain)
+}
+
+// getRecentlyAddedAlbums lists the count albums which were added last.
+func getRecentlyAddedAlbums(config administration.Configuration, count int) (string, error) {
+    var albums struct {
+        Albums []Album `json:"albums"`
+    }
+    result, err := callMethod(config, `AudioLibrary.GetRecentlyAddedAlbums`, map[string]interface{} {
+        `properties`: []string{`title`, `artist`, `year`},
+        `limits`: map[string]interface{} {
+            `end`: count,
+        },
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &albums)
+    }
+    if err != nil {
+        return ``, err
+    }
+    plain := `No albums found.`
+    lines := make([]string, len(albums.Albums))
+    rows := make([][]string, len(albums.Albums))
+    for i, album := range albums.Albums {
+        artist := strings.Join(album.Artist, `, `)
+        lines[i] = fmt.Sprintf(`%d - %s - %s (%d)`, album.AlbumID, artist, album.Title, album.Year)
+        rows[i] = []string{strconv.Itoa(album.AlbumID), artist, album.Title, strconv.Itoa(album.Year)}
+    }
+    if len(lines) > 0 {
+        plain = strings.Join(lines, "\n")
+    }
+    return formatListing(config, albums.Albums, []string{`ID`, `Artist`, `Album`, `Year`}, rows, plain)
+}
+
+// findSongs searches the music library for songs whose title
+// contains the query.
+func findSongs(config administration.Configuration, query string) ([]Song, error) {
+    var songs struct {
+        Songs []Song `json:"songs"`
+    }
+    result, err := callMethod(config, `AudioLibrary.GetSongs`, map[string]interface{} {
+        `properties`: []string{`title`, `artist`, `album`},
+        `filter`: map[string]interface{} {
+            `field`: `title`,
+            `operator`: `contains`,
+            `value`: query,
+        },
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &songs)
+    }
+    return songs.Songs, err
+}
+
+// formatSongs lists the songs with their ids, one song per line.
+func formatSongs(songs []Song) string {
+    if len(songs) == 0 {
+        return `No songs found.`
+    }
+    lines := make([]string, len(songs))
+    for i, song := range songs {
+        lines[i] = song.String()
+    }
+    return strings.Join(lines, "\n")
+}
+
+// String returns the id, the artists, the title and the album of the song.
+func (self Song) String() string {
+    line := strconv.Itoa(self.SongID) + ` - ` + strings.Join(self.Artist, `, `) + ` - ` + self.Title
+    if len(self.Album) > 0 {
+        line += ` (` + self.Album + `)`
+    }
+    return line
+}
+
+// createSongRows creates the rows of the table listing the songs.
+func createSongRows(songs []Song) [][]string {
+    rows := make([][]string, len(songs))
+    for i, song := range songs {
+        rows[i] = []string{strconv.Itoa(song.SongID), song.Title, strings.Join(song.Artist, `, `), song.Album}
+    }
+    return rows
+}
+
+// createSongItems turns songs into items which can be selected.
+func createSongItems(songs []Song) []selectableItem {
+    items := make([]selectableItem, len(songs))
+    for i, song := range songs {
+        items[i] = selectableItem{song.String(), map[string]interface{} {`songid`: song.SongID}, `audio`}
+    }
+    return items
+}
+
+// videoItemTypeNames returns the sorted names of all video item types.
+func videoItemTypeNames() []string {
+    names := []string{}
+    for name := range videoItemTypes {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// createVideoItemParams returns the name of the item type used inside the
+// method names and the params identifying the item, e.g. {"movieid":1}.
+func createVideoItemParams(itemType, id string) (string, map[string]interface{}, error) {
+    methodName, success := videoItemTypes[itemType]
+    if !success {
+        return ``, nil, errors.New(`Unknown type ` + itemType + `, use one of ` + strings.Join(videoItemTypeNames(), `, `) + `.`)
+    }
+    itemID, err := strconv.Atoi(id)
+    if err != nil {
+        return ``, nil, errors.New(`Illegal id ` + id + `.`)
+    }
+    return methodName, map[string]interface{} {
+        itemType + `id`: itemID,
+    }, nil
+}
+
+// detailProperties are the properties requested by details for each video item type.
+var detailProperties = map[string][]string {
+    `movie`: []string{`title`, `year`, `genre`, `runtime`, `rating`, `plot`, `cast`, `file`},
+    `tvshow`: []string{`title`, `year`, `genre`, `rating`, `plot`, `cast`, `file`},
+    `episode`: []string{`title`, `showtitle`, `season`, `episode`, `runtime`, `rating`, `plot`, `cast`, `file`},
+    `musicvideo`: []string{`title`, `artist`, `year`, `genre`, `runtime`, `rating`, `plot`, `file`},
+}
+
+// Actor is a member of the cast of a video.
+type Actor struct {
+    Name string `json:"name"`
+    Role string `json:"role"`
+}
+
+// VideoDetails are the details of an item of the video library.
+// Properties which don't exist for the type of the item stay empty.
+type VideoDetails struct {
+    Title string `json:"title"`
+    ShowTitle string `json:"showtitle,omitempty"`
+    Season int `json:"season,omitempty"`
+    Episode int `json:"episode,omitempty"`
+    Artist []string `json:"artist,omitempty"`
+    Year int `json:"year,omitempty"`
+    Genre []string `json:"genre,omitempty"`
+    // Runtime is the length of the video in seconds.
+    Runtime int `json:"runtime,omitempty"`
+    Rating float64 `json:"rating"`
+    Plot string `json:"plot"`
+    Cast []Actor `json:"cast,omitempty"`
+    File string `json:"file"`
+}
+
+// createDetailRows creates the rows of the table listing the details,
+// one property per row. Empty properties are left out.
+func createDetailRows(details VideoDetails) [][]string {
+    rows := [][]string{{`Title`, details.Title}}
+    if len(details.ShowTitle) > 0 {
+        rows = append(rows, []string{`Show`, details.ShowTitle}, []string{`Episode`, fmt.Sprintf(`S%02dE%02d`, details.Season, details.Episode)})
+    }
+    if len(details.Artist) > 0 {
+        rows = append(rows, []string{`Artist`, strings.Join(details.Artist, `, `)})
+    }
+    if details.Year > 0 {
+        rows = append(rows, []string{`Year`, strconv.Itoa(details.Year)})
+    }
+    if len(details.Genre) > 0 {
+        rows = append(rows, []string{`Genre`, strings.Join(details.Genre, `, `)})
+    }
+    if details.Runtime > 0 {
+        rows = append(rows, []string{`Runtime`, formatDuration(details.Runtime)})
+    }
+    rows = append(rows, []string{`Rating`, strconv.FormatFloat(details.Rating, 'f', 1, 64)})
+    if len(details.Cast) > 0 {
+        actors := make([]string, len(details.Cast))
+        for i, actor := range details.Cast {
+            actors[i] = actor.Name
+            if len(actor.Role) > 0 {
+                actors[i] += ` (` + actor.Role + `)`
+            }
+        }
+        rows = append(rows, []string{`Cast`, strings.Join(actors, `, `)})
+    }
+    rows = append(rows, []string{`File`, details.File}, []string{`Plot`, details.Plot})
+    return rows
+}
+
+// getVideoDetails fetches the details of the video library item of
+// itemType with the id and displays them.
+func getVideoDetails(config administration.Configuration, itemType, id string) (string, error) {
+    methodName, paramMap, err := createVideoItemParams(itemType, id)
+    if err != nil {
+        return ``, err
+    }
+    paramMap[`properties`] = detailProperties[itemType]
+    result, err := callMethod(config, `VideoLibrary.Get` + methodName + `Details`, paramMap)
+    if err != nil {
+        return ``, err
+    }
+    var response map[string]VideoDetails
+    if err = json.Unmarshal(result, &response); err != nil {
+        return ``, err
+    }
+    details := response[itemType + `details`]
+    rows := createDetailRows(details)
+    lines := make([]string, len(rows))
+    for i, row := range rows {
+        lines[i] = row[0] + `: ` + row[1]
+    }
+    return formatListing(config, details, []string{`Property`, `Value`}, rows, strings.Join(lines, "\n"))
+}
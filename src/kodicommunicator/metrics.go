@@ -0,0 +1,205 @@
+package kodicommunicator
+
+import (
+    "administration"
+    "logging"
+
+    "encoding/json"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    defaultMetricsAddress = `:9101`
+    // reconnectDelay is the time the daemon waits before it
+    // connects to Kodi again after the connection was lost.
+    reconnectDelay = 5 * time.Second
+)
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// playbackState is the state of Kodi which is exposed as metrics.
+type playbackState struct {
+    sync.Mutex
+    up bool
+    playing bool
+    muted bool
+    volume int
+    item Item
+}
+
+// runDaemon serves the playback state as Prometheus metrics on address and
+// keeps it up to date with the notifications sent by Kodi. If the connection
+// to Kodi is lost the daemon reconnects after reconnectDelay.
+func runDaemon(config administration.Configuration, address string) error {
+    state := &playbackState{}
+    listener, err := net.Listen(`tcp`, address)
+    if err != nil {
+        return err
+    }
+    mux := http.NewServeMux()
+    mux.HandleFunc(`/metrics`, func(writer http.ResponseWriter, request *http.Request) {
+        writer.Header().Set(`Content-Type`, `text/plain; version=0.0.4`)
+        io.WriteString(writer, state.render())
+    })
+    serverErrors := make(chan error, 1)
+    go func() {
+        serverErrors <- http.Serve(listener, mux)
+    }()
+    logging.Info(`Serving metrics on`, listener.Addr().String() + `/metrics`)
+
+    for {
+        select {
+        case err := <-serverErrors:
+            return err
+        default:
+        }
+        err := state.load(config)
+        if err == nil {
+            state.setUp(true)
+            err = subscribeNotifications(config, func(notification Notification) error {
+                return state.handle(config, notification)
+            })
+        }
+        state.setUp(false)
+        logging.Error(`Connection to Kodi lost:`, err.Error())
+        time.Sleep(reconnectDelay)
+    }
+}
+
+// setUp stores whether the daemon is connected to Kodi.
+func (self *playbackState) setUp(up bool) {
+    self.Lock()
+    defer self.Unlock()
+    self.up = up
+}
+
+// load requests the complete state from Kodi. It is used after connecting
+// because notifications only contain the changes.
+func (self *playbackState) load(config administration.Configuration) error {
+    var application struct {
+        Volume int `json:"volume"`
+        Muted bool `json:"muted"`
+    }
+    result, err := callMethod(config, `Application.GetProperties`, map[string]interface{} {
+        `properties`: []string{`volume`, `muted`},
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &application)
+    }
+    if err != nil {
+        return err
+    }
+
+    players, err := getActivePlayers(config)
+    if err != nil {
+        return err
+    }
+    var item Item
+    playing := false
+    if len(players) > 0 {
+        if item, err = getCurrentItem(config, players[0].PlayerID); err != nil {
+            return err
+        }
+        var properties struct {
+            Speed int `json:"speed"`
+        }
+        result, err := callMethod(config, `Player.GetProperties`, map[string]interface{} {
+            `playerid`: players[0].PlayerID,
+            `properties`: []string{`speed`},
+        })
+        if err == nil {
+            err = json.Unmarshal(result, &properties)
+        }
+        if err != nil {
+            return err
+        }
+        playing = properties.Speed != 0
+    }
+
+    self.Lock()
+    defer self.Unlock()
+    self.volume = application.Volume
+    self.muted = application.Muted
+    self.playing = playing
+    self.item = item
+    return nil
+}
+
+// handle updates the state according to a notification of Kodi.
+func (self *playbackState) handle(config administration.Configuration, notification Notification) error {
+    switch notification.Method {
+    case `Player.OnPlay`, `Player.OnResume`, `Player.OnAVStart`:
+        var data struct {
+            Player ActivePlayer `json:"player"`
+        }
+        if err := json.Unmarshal(notification.Params.Data, &data); err != nil {
+            return err
+        }
+        item, err := getCurrentItem(config, data.Player.PlayerID)
+        if err != nil {
+            return err
+        }
+        self.Lock()
+        defer self.Unlock()
+        self.playing = true
+        self.item = item
+    case `Player.OnPause`:
+        self.Lock()
+        defer self.Unlock()
+        self.playing = false
+    case `Player.OnStop`:
+        self.Lock()
+        defer self.Unlock()
+        self.playing = false
+        self.item = Item{}
+    case `Application.OnVolumeChanged`:
+        var data struct {
+            Volume float64 `json:"volume"`
+            Muted bool `json:"muted"`
+        }
+        if err := json.Unmarshal(notification.Params.Data, &data); err != nil {
+            return err
+        }
+        self.Lock()
+        defer self.Unlock()
+        self.volume = int(data.Volume)
+        self.muted = data.Muted
+    }
+    return nil
+}
+
+// render formats the state in the Prometheus text format.
+func (self *playbackState) render() string {
+    self.Lock()
+    defer self.Unlock()
+
+    var output strings.Builder
+    writeMetric(&output, `kodi_up`, `Whether krm is connected to Kodi.`, ``, boolToMetric(self.up))
+    writeMetric(&output, `kodi_is_playing`, `Whether Kodi is playing.`, ``, boolToMetric(self.playing))
+    writeMetric(&output, `kodi_volume`, `The volume of Kodi in percent.`, ``, self.volume)
+    writeMetric(&output, `kodi_muted`, `Whether Kodi is muted.`, ``, boolToMetric(self.muted))
+    if len(self.item.Type) > 0 {
+        labels := `{type="` + metricLabelEscaper.Replace(self.item.Type) + `",title="` + metricLabelEscaper.Replace(self.item.DisplayTitle()) + `"}`
+        writeMetric(&output, `kodi_current_item`, `The item which is currently played.`, labels, 1)
+    }
+    return output.String()
+}
+
+// writeMetric writes a gauge together with its help and type lines.
+func writeMetric(output *strings.Builder, name, help, labels string, value int) {
+    fmt.Fprintf(output, "# HELP %s %s\n# TYPE %s gauge\n%s%s %d\n", name, help, name, name, labels, value)
+}
+
+// boolToMetric converts a boolean to the value of a metric.
+func boolToMetric(value bool) int {
+    if value {
+        return 1
+    }
+    return 0
+}
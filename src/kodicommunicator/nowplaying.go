@@ -0,0 +1,94 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "strings"
+    "text/template"
+)
+
+const (
+    // defaultNowPlayingTemplate is used by nowplaying if no output template is configured.
+    defaultNowPlayingTemplate = `{{.Title}} - {{.Elapsed}} / {{.Duration}} ({{printf "%.0f" .Percentage}}%)`
+    // defaultStatusLineTemplate is used by statusline if no output template is configured.
+    defaultStatusLineTemplate = `{{if .Playing}}▶{{else}}⏸{{end}} {{.Title}} {{printf "%.0f" .Percentage}}%`
+)
+
+// NowPlaying contains everything known about the current playback.
+// It is the data passed to the output template.
+type NowPlaying struct {
+    Title string
+    Type string
+    Elapsed Time
+    Duration Time
+    Percentage float64
+    Speed int
+    Playing bool
+}
+
+// getNowPlaying collects the item and the progress of the active player.
+// The second return value is false if nothing is playing.
+func getNowPlaying(config administration.Configuration) (NowPlaying, bool, error) {
+    var nowPlaying NowPlaying
+    players, err := getActivePlayers(config)
+    if err != nil || len(players) == 0 {
+        return nowPlaying, false, err
+    }
+    item, err := getCurrentItem(config, players[0].PlayerID)
+    if err != nil {
+        return nowPlaying, false, err
+    }
+    properties, err := getPlayerProperties(config)
+    if err != nil {
+        return nowPlaying, false, err
+    }
+    nowPlaying.Title = item.DisplayTitle()
+    nowPlaying.Type = item.Type
+    nowPlaying.Elapsed = properties.Time
+    nowPlaying.Duration = properties.TotalTime
+    nowPlaying.Percentage = properties.Percentage
+    nowPlaying.Speed = properties.Speed
+    nowPlaying.Playing = properties.Speed != 0
+    return nowPlaying, true, nil
+}
+
+// formatNowPlaying renders the current playback with the configured
+// output template or the default one, colored by whether it is paused.
+func formatNowPlaying(config administration.Configuration) (string, error) {
+    nowPlaying, playing, err := getNowPlaying(config)
+    if err != nil {
+        return ``, err
+    }
+    if !playing {
+        return `Nothing is playing.`, nil
+    }
+    output, err := executeOutputTemplate(config, defaultNowPlayingTemplate, nowPlaying)
+    return colorize(config, output, playbackColor(nowPlaying)), err
+}
+
+// formatStatusLine renders the current playback as a single line for status
+// bars. The line is empty if nothing is playing.
+func formatStatusLine(config administration.Configuration) (string, error) {
+    nowPlaying, playing, err := getNowPlaying(config)
+    if err != nil || !playing {
+        return ``, err
+    }
+    output, err := executeOutputTemplate(config, defaultStatusLineTemplate, nowPlaying)
+    return colorize(config, output, playbackColor(nowPlaying)), err
+}
+
+// executeOutputTemplate renders data with the output template passed on the
+// command line or with defaultTemplate if none was passed.
+func executeOutputTemplate(config administration.Configuration, defaultTemplate string, data interface{}) (string, error) {
+    text := defaultTemplate
+    if len(config.OutputTemplate) > 0 {
+        text = config.OutputTemplate
+    }
+    outputTemplate, err := template.New(`output`).Parse(text)
+    if err != nil {
+        return ``, err
+    }
+    var output strings.Builder
+    err = outputTemplate.Execute(&output, data)
+    return output.String(), err
+}
@@ -0,0 +1,55 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "reflect"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+)
+
+const (
+    // PlainOutput lists one item per line, it is the default.
+    PlainOutput = `plain`
+    // TableOutput lists the items in aligned columns.
+    TableOutput = `table`
+    // JsonOutput prints the items as JSON.
+    JsonOutput = `json`
+)
+
+// OutputFormats are the formats listings can be printed in.
+var OutputFormats = []string{PlainOutput, TableOutput, JsonOutput}
+
+// formatListing formats the items of a listing in the configured output
+// format. The table consists of an index column followed by the columns
+// of header and rows with a bold header, plain is used for the plain
+// output and if there are no items to show in a table. Empty listings
+// are printed as [] in JSON.
+func formatListing(config administration.Configuration, items interface{}, header []string, rows [][]string, plain string) (string, error) {
+    switch config.OutputFormat {
+    case JsonOutput:
+        if value := reflect.ValueOf(items); value.Kind() == reflect.Slice && value.IsNil() {
+            items = []interface{}{}
+        }
+        output, err := json.MarshalIndent(items, ``, `    `)
+        return string(output), err
+    case TableOutput:
+        if len(rows) == 0 {
+            return plain, nil
+        }
+        var output strings.Builder
+        writer := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+        writer.Write([]byte(`#` + "\t" + strings.Join(header, "\t") + "\n"))
+        for i, row := range rows {
+            writer.Write([]byte(strconv.Itoa(i + 1) + "\t" + strings.Join(row, "\t") + "\n"))
+        }
+        err := writer.Flush()
+        lines := strings.SplitN(strings.TrimSuffix(output.String(), "\n"), "\n", 2)
+        lines[0] = colorize(config, lines[0], colorBold)
+        return strings.Join(lines, "\n"), err
+    default:
+        return plain, nil
+    }
+}
@@ -0,0 +1,337 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "errors"
+    "math"
+    "strconv"
+    "time"
+)
+
+const (
+    // videoFPSLabel is the info label containing the frame rate of the current video.
+    videoFPSLabel = `Player.Process(VideoFPS)`
+    // defaultPlayerID is used if no player is active.
+    defaultPlayerID = 1
+    // playerIDCacheTTL is the time a detected player id is reused
+    // before the active players are requested again.
+    playerIDCacheTTL = 5 * time.Second
+)
+
+// ActivePlayer is a player returned by Player.GetActivePlayers.
+type ActivePlayer struct {
+    PlayerID int `json:"playerid"`
+    Type string `json:"type"`
+}
+
+// Item is the item a player is playing.
+type Item struct {
+    ID int `json:"id"`
+    Type string `json:"type"`
+    Label string `json:"label"`
+    Title string `json:"title"`
+    File string `json:"file"`
+}
+
+// DisplayTitle returns the title of the item or its label
+// if it has no title, e.g. for files outside of the library.
+func (self Item) DisplayTitle() string {
+    if len(self.Title) > 0 {
+        return self.Title
+    }
+    return self.Label
+}
+
+// libraryItemTypes are the types of items Player.Open can open by their id.
+var libraryItemTypes = map[string]bool {
+    `movie`: true,
+    `episode`: true,
+    `musicvideo`: true,
+    `song`: true,
+}
+
+// playerIDCache holds the last detected player id until it expires.
+var playerIDCache struct {
+    playerID int
+    expires time.Time
+}
+
+// getActivePlayers asks Kodi for all active players.
+func getActivePlayers(config administration.Configuration) ([]ActivePlayer, error) {
+    var players []ActivePlayer
+    result, err := callMethod(config, `Player.GetActivePlayers`, nil)
+    if err == nil {
+        err = json.Unmarshal(result, &players)
+    }
+    return players, err
+}
+
+// getActivePlayerID returns the id of the first active player or
+// defaultPlayerID if no player is active. The id is cached for
+// playerIDCacheTTL so commands sent in quick succession don't need
+// to detect it again.
+func getActivePlayerID(config administration.Configuration) (int, error) {
+    if time.Now().Before(playerIDCache.expires) {
+        return playerIDCache.playerID, nil
+    }
+    players, err := getActivePlayers(config)
+    if err != nil {
+        return 0, err
+    }
+    playerID := defaultPlayerID
+    if len(players) > 0 {
+        playerID = players[0].PlayerID
+    }
+    playerIDCache.playerID = playerID
+    playerIDCache.expires = time.Now().Add(playerIDCacheTTL)
+    return playerID, nil
+}
+
+// getCurrentItem asks Kodi for the item the player is playing.
+func getCurrentItem(config administration.Configuration, playerID int) (Item, error) {
+    var item struct {
+        Item Item `json:"item"`
+    }
+    result, err := callMethod(config, `Player.GetItem`, map[string]interface{} {
+        `playerid`: playerID,
+        `properties`: []string{`title`, `file`},
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &item)
+    }
+    return item.Item, err
+}
+
+// getVideoFPS asks Kodi for the frame rate of the current video.
+func getVideoFPS(config administration.Configuration) (float64, error) {
+    var labels map[string]string
+    result, err := callMethod(config, `XBMC.GetInfoLabels`, map[string]interface{} {
+        `labels`: []string{videoFPSLabel},
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &labels)
+    }
+    if err != nil {
+        return 0, err
+    }
+    fps, err := strconv.ParseFloat(labels[videoFPSLabel], 64)
+    if err != nil || fps <= 0 {
+        return 0, errors.New(`The frame rate of the current video is unknown.`)
+    }
+    return fps, nil
+}
+
+// seekFrames jumps the number of frames forth, or back if frames is
+// negative, by seeking to the time calculated from the frame rate.
+func seekFrames(config administration.Configuration, frames int) error {
+    fps, err := getVideoFPS(config)
+    if err != nil {
+        return err
+    }
+    properties, err := getPlayerProperties(config)
+    if err != nil {
+        return err
+    }
+    playerID, err := getActivePlayerID(config)
+    if err != nil {
+        return err
+    }
+    target := properties.Time.toMilliseconds() + int(math.Round(float64(frames) * 1000 / fps))
+    if target < 0 {
+        target = 0
+    }
+    _, err = callMethod(config, `Player.Seek`, map[string]interface{} {
+        `playerid`: playerID,
+        `value`: timeFromMilliseconds(target),
+    })
+    return err
+}
+
+// stopAllPlayers stops every active player.
+func stopAllPlayers(config administration.Configuration) error {
+    players, err := getActivePlayers(config)
+    if err != nil {
+        return err
+    }
+    for _, player := range players {
+        if _, err := callMethod(config, `Player.Stop`, map[string]interface{} {
+            `playerid`: player.PlayerID,
+        }); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// stopPlayback stops the first active player. Nothing is sent if no
+// player is active because the playback is stopped already.
+func stopPlayback(config administration.Configuration) (string, error) {
+    players, err := getActivePlayers(config)
+    if err != nil {
+        return ``, err
+    }
+    if len(players) == 0 {
+        return `Nothing is playing.`, nil
+    }
+    _, err = callMethod(config, `Player.Stop`, map[string]interface{} {
+        `playerid`: players[0].PlayerID,
+    })
+    return ``, err
+}
+
+// invalidatePlayerIDCacheFor clears the cached player id if the
+// method starts or stops a player.
+func invalidatePlayerIDCacheFor(method string) {
+    if method == `Player.Open` || method == `Player.Stop` {
+        InvalidatePlayerIDCache()
+    }
+}
+
+// InvalidatePlayerIDCache clears the cached player id, e.g. after
+// switching to another Kodi installation.
+func InvalidatePlayerIDCache() {
+    playerIDCache.expires = time.Time{}
+}
+
+// playedVideo is a movie or an episode together with the time it was played last.
+type playedVideo struct {
+    MovieID int `json:"movieid"`
+    EpisodeID int `json:"episodeid"`
+    Title string `json:"title"`
+    LastPlayed string `json:"lastplayed"`
+}
+
+// playPause resumes the playback of the active player if resume is set and
+// pauses it otherwise. If no player is active and resume is set, the video
+// played last is resumed instead. If configured, the action "playpause" is
+// sent instead, but only if the playback isn't in the requested state yet.
+func playPause(config administration.Configuration, resume bool) (string, error) {
+    players, err := getActivePlayers(config)
+    if err != nil {
+        return ``, err
+    }
+    if len(players) == 0 {
+        if resume {
+            return resumeLastPlayed(config)
+        }
+        return `Nothing is playing.`, nil
+    }
+    if config.PlayPauseAction {
+        properties, err := getPlayerProperties(config)
+        if err != nil {
+            return ``, err
+        }
+        if resume == (properties.Speed != 0) {
+            return ``, nil
+        }
+        _, err = callMethod(config, `Input.ExecuteAction`, map[string]interface{} {
+            `action`: `playpause`,
+        })
+        return ``, err
+    }
+    _, err = callMethod(config, `Player.PlayPause`, map[string]interface{} {
+        `playerid`: players[0].PlayerID,
+        `play`: resume,
+    })
+    return ``, err
+}
+
+// resumeLastPlayed opens the movie or episode which was played last
+// at the position it was stopped.
+func resumeLastPlayed(config administration.Configuration) (string, error) {
+    var library struct {
+        Movies []playedVideo `json:"movies"`
+        Episodes []playedVideo `json:"episodes"`
+    }
+    for _, method := range []string{`VideoLibrary.GetMovies`, `VideoLibrary.GetEpisodes`} {
+        result, err := callMethod(config, method, map[string]interface{} {
+            `properties`: []string{`title`, `lastplayed`},
+            `sort`: map[string]interface{} {
+                `method`: `lastplayed`,
+                `order`: `descending`,
+            },
+            `limits`: map[string]interface{} {
+                `end`: 1,
+            },
+        })
+        if err == nil {
+            err = json.Unmarshal(result, &library)
+        }
+        if err != nil {
+            return ``, err
+        }
+    }
+
+    var item map[string]interface{}
+    var last playedVideo
+    if len(library.Movies) > 0 && len(library.Movies[0].LastPlayed) > 0 {
+        last = library.Movies[0]
+        item = map[string]interface{} {`movieid`: last.MovieID}
+    }
+    // the time is formatted like "2006-01-02 15:04:05" so it can be compared as string
+    if len(library.Episodes) > 0 && library.Episodes[0].LastPlayed > last.LastPlayed {
+        last = library.Episodes[0]
+        item = map[string]interface{} {`episodeid`: last.EpisodeID}
+    }
+    if item == nil {
+        return `Nothing to resume.`, nil
+    }
+    _, err := callMethod(config, `Player.Open`, map[string]interface{} {
+        `item`: item,
+        `options`: map[string]interface{} {
+            `resume`: true,
+        },
+    })
+    if err != nil {
+        return ``, err
+    }
+    return `Resuming ` + last.Title + `.`, nil
+}
+
+// restartPlayback stops the current playback and opens the same item again
+// at the time it was stopped, e.g. if the stream of an add-on stalls.
+func restartPlayback(config administration.Configuration) (string, error) {
+    players, err := getActivePlayers(config)
+    if err != nil {
+        return ``, err
+    }
+    if len(players) == 0 {
+        return `Nothing is playing.`, nil
+    }
+    playerID := players[0].PlayerID
+    item, err := getCurrentItem(config, playerID)
+    if err != nil {
+        return ``, err
+    }
+    properties, err := getPlayerProperties(config)
+    if err != nil {
+        return ``, err
+    }
+
+    var openItem map[string]interface{}
+    if libraryItemTypes[item.Type] && item.ID > 0 {
+        openItem = map[string]interface{} {item.Type + `id`: item.ID}
+    } else if len(item.File) > 0 {
+        openItem = map[string]interface{} {`file`: item.File}
+    } else {
+        return ``, errors.New(`The current item can't be opened again.`)
+    }
+
+    if _, err := callMethod(config, `Player.Stop`, map[string]interface{} {
+        `playerid`: playerID,
+    }); err != nil {
+        return ``, err
+    }
+    _, err = callMethod(config, `Player.Open`, map[string]interface{} {
+        `item`: openItem,
+        `options`: map[string]interface{} {
+            `resume`: properties.Time,
+        },
+    })
+    if err != nil {
+        return ``, err
+    }
+    return `Restarted ` + item.DisplayTitle() + ` at ` + properties.Time.String() + `.`, nil
+}
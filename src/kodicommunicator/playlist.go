@@ -0,0 +1,38 @@
+package kodicommunicator
+
+import (
+    "errors"
+    "strconv"
+)
+
+// playlist is one of Kodi's playlists together with the media
+// type of the files which may be added to it.
+type playlist struct {
+    ID int
+    Media string
+}
+
+// playlists maps the names of Kodi's playlists to their ids and media.
+var playlists = map[string]playlist {
+    `audio`: {0, `music`},
+    `video`: {1, `video`},
+    `picture`: {2, `pictures`},
+}
+
+// getPlaylist returns the playlist with the given name.
+func getPlaylist(name string) (playlist, error) {
+    if playlist, success := playlists[name]; success {
+        return playlist, nil
+    }
+    return playlist{}, errors.New(`Unknown playlist ` + name + `, use audio, video or picture.`)
+}
+
+// parsePosition parses a position inside a playlist, the first item
+// has the position 0.
+func parsePosition(value, cliName string) (int, error) {
+    position, err := strconv.Atoi(value)
+    if err != nil || position < 0 {
+        return 0, errors.New(`Illegal position ` + value + `. See "help ` + cliName + `" for usage information.`)
+    }
+    return position, nil
+}
@@ -0,0 +1,117 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "fmt"
+    "os"
+    "os/signal"
+    "strings"
+    "time"
+)
+
+const progressBarWidth = 40
+
+// Time represents a time or a duration as it is returned by Kodi.
+type Time struct {
+    Hours int `json:"hours"`
+    Minutes int `json:"minutes"`
+    Seconds int `json:"seconds"`
+    Milliseconds int `json:"milliseconds"`
+}
+
+// String formats the time like "1:23:45" or "23:45".
+func (self Time) String() string {
+    return formatDuration((self.Hours * 60 + self.Minutes) * 60 + self.Seconds)
+}
+
+// formatDuration formats seconds like "1:23:45", the hours are left out
+// if they are zero, e.g. "23:45". All durations shown should use it.
+func formatDuration(seconds int) string {
+    if seconds >= 3600 {
+        return fmt.Sprintf(`%d:%02d:%02d`, seconds / 3600, seconds / 60 % 60, seconds % 60)
+    }
+    return fmt.Sprintf(`%d:%02d`, seconds / 60, seconds % 60)
+}
+
+// toMilliseconds returns the time in milliseconds.
+func (self Time) toMilliseconds() int {
+    return ((self.Hours * 60 + self.Minutes) * 60 + self.Seconds) * 1000 + self.Milliseconds
+}
+
+// timeFromMilliseconds splits milliseconds into a Time.
+func timeFromMilliseconds(milliseconds int) Time {
+    return Time {
+        Hours: milliseconds / 3600000,
+        Minutes: milliseconds / 60000 % 60,
+        Seconds: milliseconds / 1000 % 60,
+        Milliseconds: milliseconds % 1000,
+    }
+}
+
+// PlayerProperties contains the properties of a player needed
+// to display the progress of the current playback.
+type PlayerProperties struct {
+    Time Time `json:"time"`
+    TotalTime Time `json:"totaltime"`
+    Percentage float64 `json:"percentage"`
+    Speed int `json:"speed"`
+}
+
+// getPlayerProperties asks Kodi for the progress of the current playback.
+func getPlayerProperties(config administration.Configuration) (PlayerProperties, error) {
+    var properties PlayerProperties
+    playerID, err := getActivePlayerID(config)
+    if err != nil {
+        return properties, err
+    }
+    result, err := callMethod(config, `Player.GetProperties`, map[string]interface{} {
+        `playerid`: playerID,
+        `properties`: []string{`time`, `totaltime`, `percentage`, `speed`},
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &properties)
+    }
+    return properties, err
+}
+
+// renderProgressBar creates a single line showing the elapsed and the total
+// time and the percentage of the playback.
+func renderProgressBar(properties PlayerProperties) string {
+    filled := int(properties.Percentage / 100 * progressBarWidth)
+    if filled > progressBarWidth {
+        filled = progressBarWidth
+    } else if filled < 0 {
+        filled = 0
+    }
+    bar := strings.Repeat(`=`, filled) + strings.Repeat(` `, progressBarWidth - filled)
+    return fmt.Sprintf(`[%s] %s / %s (%.0f%%)`, bar, properties.Time, properties.TotalTime, properties.Percentage)
+}
+
+// watchProgress polls the progress of the current playback every second and
+// redraws the progress bar until the user presses Ctrl-C.
+func watchProgress(config administration.Configuration) error {
+    interrupt := make(chan os.Signal, 1)
+    signal.Notify(interrupt, os.Interrupt)
+    defer signal.Stop(interrupt)
+
+    ticker := time.NewTicker(time.Second)
+    defer ticker.Stop()
+
+    for {
+        properties, err := getPlayerProperties(config)
+        if err != nil {
+            fmt.Println()
+            return err
+        }
+        fmt.Print("\r" + renderProgressBar(properties))
+
+        select {
+        case <-interrupt:
+            fmt.Println()
+            return nil
+        case <-ticker.C:
+        }
+    }
+}
@@ -0,0 +1,32 @@
+package kodicommunicator
+
+import (
+    "encoding/json"
+    "strconv"
+    "strings"
+)
+
+// Channel is a TV or radio channel of the PVR.
+type Channel struct {
+    ChannelID int `json:"channelid"`
+    Label string `json:"label"`
+}
+
+// formatChannels lists the channels returned by PVR.GetChannels
+// with their ids, one channel per line.
+func formatChannels(result json.RawMessage) (string, error) {
+    var channels struct {
+        Channels []Channel `json:"channels"`
+    }
+    if err := json.Unmarshal(result, &channels); err != nil {
+        return ``, err
+    }
+    if len(channels.Channels) == 0 {
+        return `No channels found.`, nil
+    }
+    lines := make([]string, len(channels.Channels))
+    for i, channel := range channels.Channels {
+        lines[i] = strconv.Itoa(channel.ChannelID) + ` - ` + channel.Label
+    }
+    return strings.Join(lines, "\n"), nil
+}
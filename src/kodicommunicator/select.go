@@ -0,0 +1,95 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// selectableItem is an entry of a listing which can be played
+// or queued after it was selected.
+type selectableItem struct {
+    Label string
+    // Item identifies the entry for Player.Open and Playlist.Add,
+    // e.g. {"songid":1}.
+    Item map[string]interface{}
+    // Playlist is the name of the playlist the item is queued in.
+    Playlist string
+}
+
+// getSelectMode removes the flag --select from params and returns "play"
+// for --select, "queue" for --select=queue or an empty string if the flag
+// is missing.
+func getSelectMode(params []string) (string, []string, error) {
+    mode := ``
+    remaining := []string{}
+    for _, param := range params {
+        if param == `--select` || param == `--select=play` {
+            mode = `play`
+        } else if param == `--select=queue` {
+            mode = `queue`
+        } else if strings.HasPrefix(param, `--select=`) {
+            return ``, params, errors.New(`Illegal parameter ` + param + `, use --select or --select=queue.`)
+        } else {
+            remaining = append(remaining, param)
+        }
+    }
+    return mode, remaining, nil
+}
+
+// selectItem lists the items numbered, reads the number of an item from
+// stdin and plays or queues it depending on mode. An empty selection
+// cancels without error. It fails if stdin is no terminal, e.g. because
+// the commands are read from it.
+func selectItem(config administration.Configuration, mode string, items []selectableItem) (string, error) {
+    if len(items) == 0 {
+        return `Nothing to select.`, nil
+    }
+    if !isTerminal(os.Stdin) {
+        return ``, errors.New(`The selection needs to be entered on a terminal. Call the command without --select in scripts.`)
+    }
+    for i, item := range items {
+        fmt.Printf("%d) %s\n", i + 1, item.Label)
+    }
+    fmt.Fprint(os.Stderr, `Select a number (empty to cancel): `)
+    answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    if err != nil && err != io.EOF {
+        return ``, err
+    }
+    answer = strings.TrimSpace(answer)
+    if len(answer) == 0 {
+        return ``, nil
+    }
+    number, err := strconv.Atoi(answer)
+    if err != nil || number < 1 || number > len(items) {
+        return ``, errors.New(`Illegal selection ` + answer + `, use a number from 1 to ` + strconv.Itoa(len(items)) + `.`)
+    }
+
+    selected := items[number - 1]
+    if mode == `queue` {
+        playlist, err := getPlaylist(selected.Playlist)
+        if err != nil {
+            return ``, err
+        }
+        _, err = callMethod(config, `Playlist.Add`, map[string]interface{} {
+            `playlistid`: playlist.ID,
+            `item`: selected.Item,
+        })
+        if err != nil {
+            return ``, err
+        }
+        return `Queued ` + selected.Label + `.`, nil
+    }
+    if _, err := callMethod(config, `Player.Open`, map[string]interface{} {
+        `item`: selected.Item,
+    }); err != nil {
+        return ``, err
+    }
+    return `Playing ` + selected.Label + `.`, nil
+}
@@ -0,0 +1,53 @@
+package kodicommunicator
+
+import (
+    "encoding/json"
+    "strings"
+)
+
+// settingsLevel makes the settings methods return the settings of all levels.
+const settingsLevel = `expert`
+
+// SettingEntry is a section, a category or a setting of Kodi's settings.
+type SettingEntry struct {
+    ID string `json:"id"`
+    Label string `json:"label"`
+}
+
+// formatSections lists the sections returned by Settings.GetSections.
+func formatSections(result json.RawMessage) (string, error) {
+    return formatSettingEntries(result, `sections`)
+}
+
+// formatCategories lists the categories returned by Settings.GetCategories.
+func formatCategories(result json.RawMessage) (string, error) {
+    return formatSettingEntries(result, `categories`)
+}
+
+// formatSettings lists the settings returned by Settings.GetSettings.
+func formatSettings(result json.RawMessage) (string, error) {
+    return formatSettingEntries(result, `settings`)
+}
+
+// formatSettingEntries lists the ids and labels of the entries contained
+// in the list with the given name, one entry per line.
+func formatSettingEntries(result json.RawMessage, listName string) (string, error) {
+    var lists map[string]json.RawMessage
+    if err := json.Unmarshal(result, &lists); err != nil {
+        return ``, err
+    }
+    var entries []SettingEntry
+    if list, success := lists[listName]; success {
+        if err := json.Unmarshal(list, &entries); err != nil {
+            return ``, err
+        }
+    }
+    if len(entries) == 0 {
+        return `No ` + listName + ` found.`, nil
+    }
+    lines := make([]string, len(entries))
+    for i, entry := range entries {
+        lines[i] = entry.ID + ` - ` + entry.Label
+    }
+    return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,47 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "fmt"
+    "os"
+    "time"
+)
+
+const spinnerInterval = 100 * time.Millisecond
+
+var spinnerFrames = []string{`|`, `/`, `-`, `\`}
+
+// startSpinner shows a spinner on stderr until the returned function is
+// called. Nothing is shown if quiet is configured or stdout is no terminal.
+func startSpinner(config administration.Configuration) func() {
+    if config.Quiet || !isTerminal(os.Stdout) {
+        return func() {}
+    }
+    done := make(chan bool)
+    stopped := make(chan bool)
+    go func() {
+        ticker := time.NewTicker(spinnerInterval)
+        defer ticker.Stop()
+        for frame := 0; ; frame++ {
+            fmt.Fprint(os.Stderr, "\r" + spinnerFrames[frame % len(spinnerFrames)])
+            select {
+            case <-done:
+                fmt.Fprint(os.Stderr, "\r \r")
+                close(stopped)
+                return
+            case <-ticker.C:
+            }
+        }
+    }()
+    return func() {
+        close(done)
+        <-stopped
+    }
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(file *os.File) bool {
+    info, err := file.Stat()
+    return err == nil && info.Mode() & os.ModeCharDevice != 0
+}
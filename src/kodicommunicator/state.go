@@ -0,0 +1,62 @@
+package kodicommunicator
+
+import (
+    "administration"
+    "logging"
+
+    "encoding/json"
+    "errors"
+)
+
+// rememberRequest saves the window or the item of a successful command which
+// activated a window or opened an item, so resumelast can return to it.
+// Failing to save the state doesn't fail the command.
+func rememberRequest(js string) {
+    var request CommandRequest
+    if err := json.Unmarshal([]byte(js), &request); err != nil {
+        return
+    }
+    if request.Method != `GUI.ActivateWindow` && request.Method != `Player.Open` {
+        return
+    }
+    state, err := administration.ReadState()
+    if err != nil {
+        logging.Debug(`Reading the state failed:`, err.Error())
+        return
+    }
+    if request.Method == `GUI.ActivateWindow` {
+        state.Window = request.Params
+    } else if item, success := request.Params[`item`].(map[string]interface{}); success {
+        state.Item = item
+    }
+    if err := administration.WriteState(state); err != nil {
+        logging.Debug(`Saving the state failed:`, err.Error())
+    }
+}
+
+// resumeLast returns to the item which was opened last or to the window
+// which was activated last. target selects one of them, if it is empty
+// the item is preferred.
+func resumeLast(config administration.Configuration, target string) (string, error) {
+    state, err := administration.ReadState()
+    if err != nil {
+        return ``, err
+    }
+    if target != `` && target != `item` && target != `window` {
+        return ``, errors.New(`Illegal parameter. See "help resumelast" for usage information.`)
+    }
+    if target != `window` && state.Item != nil {
+        _, err := callMethod(config, `Player.Open`, map[string]interface{} {
+            `item`: state.Item,
+            `options`: map[string]interface{} {
+                `resume`: true,
+            },
+        })
+        return ``, err
+    }
+    if target != `item` && state.Window != nil {
+        _, err := callMethod(config, `GUI.ActivateWindow`, state.Window)
+        return ``, err
+    }
+    return `Nothing to return to.`, nil
+}
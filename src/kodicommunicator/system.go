@@ -0,0 +1,38 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "errors"
+)
+
+// powerProperties maps the power methods to the property of
+// System.GetProperties telling whether the system supports them.
+var powerProperties = map[string]string {
+    `System.Shutdown`: `canshutdown`,
+    `System.Reboot`: `canreboot`,
+    `System.Suspend`: `cansuspend`,
+    `System.Hibernate`: `canhibernate`,
+}
+
+// executePowerMethod sends the power method only if the system reports
+// that it supports it, because unsupported methods are silently ignored.
+func executePowerMethod(config administration.Configuration, method string) (string, error) {
+    property := powerProperties[method]
+    var properties map[string]bool
+    result, err := callMethod(config, `System.GetProperties`, map[string]interface{} {
+        `properties`: []string{property},
+    })
+    if err == nil {
+        err = json.Unmarshal(result, &properties)
+    }
+    if err != nil {
+        return ``, err
+    }
+    if !properties[property] {
+        return ``, errors.New(`Kodi reports that the system does not support ` + method + ` (` + property + ` is false).`)
+    }
+    _, err = callMethod(config, method, nil)
+    return ``, err
+}
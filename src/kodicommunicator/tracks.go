@@ -0,0 +1,81 @@
+package kodicommunicator
+
+import (
+    "encoding/json"
+    "strconv"
+    "strings"
+)
+
+// trackProperties are the properties of Player.GetProperties requested by tracks.
+var trackProperties = []string{`audiostreams`, `currentaudiostream`, `subtitles`, `currentsubtitle`, `subtitleenabled`}
+
+// Track is an audio stream or a subtitle of the current playback.
+type Track struct {
+    Index int `json:"index"`
+    Name string `json:"name"`
+    Language string `json:"language"`
+    Codec string `json:"codec"`
+    Channels int `json:"channels"`
+}
+
+// String returns the index, the language and the name of the track
+// followed by the codec and the channels if they are known.
+func (self Track) String() string {
+    line := strconv.Itoa(self.Index) + ` - ` + self.Language + ` - ` + self.Name
+    details := []string{}
+    if len(self.Codec) > 0 {
+        details = append(details, self.Codec)
+    }
+    if self.Channels > 0 {
+        details = append(details, strconv.Itoa(self.Channels) + ` channels`)
+    }
+    if len(details) > 0 {
+        line += ` (` + strings.Join(details, `, `) + `)`
+    }
+    return line
+}
+
+// Tracks are the audio streams and subtitles of the current playback.
+type Tracks struct {
+    AudioStreams []Track `json:"audiostreams"`
+    CurrentAudioStream Track `json:"currentaudiostream"`
+    Subtitles []Track `json:"subtitles"`
+    CurrentSubtitle Track `json:"currentsubtitle"`
+    SubtitleEnabled bool `json:"subtitleenabled"`
+}
+
+// formatTracks lists the audio streams and the subtitles. The current
+// ones are marked with an asterisk, the current subtitle only if
+// subtitles are enabled.
+func formatTracks(result json.RawMessage) (string, error) {
+    var tracks Tracks
+    if err := json.Unmarshal(result, &tracks); err != nil {
+        return ``, err
+    }
+    lines := []string{`Audio streams:`}
+    lines = append(lines, formatTrackList(tracks.AudioStreams, tracks.CurrentAudioStream.Index, len(tracks.AudioStreams) > 0)...)
+    if tracks.SubtitleEnabled {
+        lines = append(lines, `Subtitles (enabled):`)
+    } else {
+        lines = append(lines, `Subtitles (disabled):`)
+    }
+    lines = append(lines, formatTrackList(tracks.Subtitles, tracks.CurrentSubtitle.Index, tracks.SubtitleEnabled)...)
+    return strings.Join(lines, "\n"), nil
+}
+
+// formatTrackList formats one track per line and marks the current one
+// if markCurrent is set.
+func formatTrackList(tracks []Track, current int, markCurrent bool) []string {
+    if len(tracks) == 0 {
+        return []string{`  none`}
+    }
+    lines := make([]string, len(tracks))
+    for i, track := range tracks {
+        if markCurrent && track.Index == current {
+            lines[i] = `* ` + track.String()
+        } else {
+            lines[i] = `  ` + track.String()
+        }
+    }
+    return lines
+}
@@ -0,0 +1,47 @@
+package kodicommunicator
+
+import (
+    "administration"
+
+    "encoding/json"
+    "errors"
+    "strconv"
+    "strings"
+)
+
+// methodNotFoundCode is returned by Kodi if it doesn't know the method,
+// e.g. because it is older than the method.
+const methodNotFoundCode = -32601
+
+// ViewMode is the view mode of the video player returned by Player.GetViewMode.
+type ViewMode struct {
+    ViewMode string `json:"viewmode"`
+    Zoom float64 `json:"zoom"`
+    PixelRatio float64 `json:"pixelratio"`
+    VerticalShift float64 `json:"verticalshift"`
+    NonLinearStretched bool `json:"nonlinearstretched"`
+}
+
+// getViewMode displays the view mode, the zoom and the pixel ratio of the
+// video player.
+func getViewMode(config administration.Configuration) (string, error) {
+    var viewMode ViewMode
+    result, err := callMethod(config, `Player.GetViewMode`, nil)
+    if jsonError, isJsonError := err.(*JsonError); isJsonError && jsonError.Code == methodNotFoundCode {
+        return ``, errors.New(`This version of Kodi does not support reading the view mode.`)
+    }
+    if err == nil {
+        err = json.Unmarshal(result, &viewMode)
+    }
+    if err != nil {
+        return ``, err
+    }
+    lines := []string{
+        `View mode: ` + viewMode.ViewMode,
+        `Zoom: ` + strconv.FormatFloat(viewMode.Zoom, 'f', 2, 64),
+        `Pixel ratio: ` + strconv.FormatFloat(viewMode.PixelRatio, 'f', 2, 64),
+        `Vertical shift: ` + strconv.FormatFloat(viewMode.VerticalShift, 'f', 2, 64),
+        `Non-linear stretch: ` + strconv.FormatBool(viewMode.NonLinearStretched),
+    }
+    return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "time"
+)
+
+// Level is the severity of a log message.
+type Level int
+
+const (
+    ErrorLevel Level = iota
+    InfoLevel
+    DebugLevel
+)
+
+var (
+    levelNames = map[Level]string {
+        ErrorLevel: `error`,
+        InfoLevel: `info`,
+        DebugLevel: `debug`,
+    }
+
+    currentLevel = InfoLevel
+    jsonFormat = false
+    output io.Writer = os.Stderr
+)
+
+// jsonMessage is a log message in the JSON format.
+type jsonMessage struct {
+    Time string `json:"time"`
+    Level string `json:"level"`
+    Message string `json:"message"`
+}
+
+// ParseLevel returns the Level for its name, e.g. "debug".
+func ParseLevel(name string) (Level, error) {
+    for level, levelName := range levelNames {
+        if levelName == name {
+            return level, nil
+        }
+    }
+    return ErrorLevel, errors.New(`Unknown log level ` + name + `, use error, info or debug.`)
+}
+
+// SetLevel sets the most detailed Level which is still logged.
+func SetLevel(level Level) {
+    currentLevel = level
+}
+
+// SetFormat switches between the human readable "text" format
+// and the "json" format.
+func SetFormat(format string) error {
+    if format != `text` && format != `json` {
+        return errors.New(`Unknown log format ` + format + `, use text or json.`)
+    }
+    jsonFormat = format == `json`
+    return nil
+}
+
+// Error logs a message which is always shown.
+func Error(values ...interface{}) {
+    log(ErrorLevel, values)
+}
+
+// Info logs a message about the normal operation.
+func Info(values ...interface{}) {
+    log(InfoLevel, values)
+}
+
+// Debug logs a detailed message for troubleshooting.
+func Debug(values ...interface{}) {
+    log(DebugLevel, values)
+}
+
+// log writes the values separated by spaces like fmt.Println if
+// the level is enabled.
+func log(level Level, values []interface{}) {
+    if level > currentLevel {
+        return
+    }
+    message := strings.TrimSuffix(fmt.Sprintln(values...), "\n")
+    if jsonFormat {
+        line, _ := json.Marshal(jsonMessage{time.Now().Format(time.RFC3339), levelNames[level], message})
+        fmt.Fprintln(output, string(line))
+    } else if level == ErrorLevel {
+        fmt.Fprintln(output, message)
+    } else {
+        fmt.Fprintln(output, levelNames[level] + `:`, message)
+    }
+}
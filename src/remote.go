@@ -1,28 +1,204 @@
 package main
 
 import (
+    "bufio"
+    "encoding/json"
     "errors"
     "fmt"
+    "io"
+    "io/ioutil"
+    "net"
     "os"
+    "strconv"
     "strings"
+    "time"
     
     "administration"
     "kodicommunicator"
+    "logging"
 )
 
-func checkAndHandleArgumentsConfig(configuration *administration.Configuration, args []string) bool {
+// checkAndHandleArgumentsConfig applies all flags to the configuration and returns
+// the remaining arguments. The first return value is true if the flags changed
+// the saved configuration.
+func checkAndHandleArgumentsConfig(configuration *administration.Configuration, args []string) (bool, []string, error) {
     changed := false
+    remaining := []string{}
     
-    for _, arg := range args {
-        if strings.HasPrefix(arg, "--host=") {
+    for i := 0; i < len(args); i++ {
+        arg := args[i]
+        if strings.HasPrefix(arg, "--addr=") {
+            if err := configuration.SetAddress(strings.TrimPrefix(arg, `--addr=`)); err != nil {
+                return changed, remaining, err
+            }
+            changed = true
+        } else if strings.HasPrefix(arg, "--host=") {
             configuration.Host = strings.Split(arg, `=`)[1]
             changed = true
         } else if strings.HasPrefix(arg, "--port=") {
             configuration.Port = strings.Split(arg, `=`)[1]
             changed = true
+        } else if strings.HasPrefix(arg, "--ws-port=") {
+            configuration.WebSocketPort = strings.TrimPrefix(arg, `--ws-port=`)
+            changed = true
+        } else if strings.HasPrefix(arg, "--add-profile=") {
+            nameAddress := strings.SplitN(strings.TrimPrefix(arg, `--add-profile=`), `=`, 2)
+            if len(nameAddress) < 2 {
+                return changed, remaining, errors.New(`The value of --add-profile needs to be like "living=kodi:8080".`)
+            }
+            if err := configuration.AddProfile(nameAddress[0], nameAddress[1]); err != nil {
+                return changed, remaining, err
+            }
+            changed = true
+        } else if strings.HasPrefix(arg, "--log-file=") {
+            configuration.LogFile = strings.TrimPrefix(arg, `--log-file=`)
+            changed = true
+        } else if strings.HasPrefix(arg, "--timeout-connect=") {
+            configuration.ConnectTimeout = strings.TrimPrefix(arg, `--timeout-connect=`)
+            if _, err := configuration.GetConnectTimeout(); err != nil {
+                return changed, remaining, err
+            }
+            changed = true
+        } else if strings.HasPrefix(arg, "--timeout-read=") {
+            configuration.ReadTimeout = strings.TrimPrefix(arg, `--timeout-read=`)
+            if _, err := configuration.GetReadTimeout(); err != nil {
+                return changed, remaining, err
+            }
+            changed = true
+        } else if strings.HasPrefix(arg, "--off-mutes=") {
+            offMutes, err := strconv.ParseBool(strings.TrimPrefix(arg, `--off-mutes=`))
+            if err != nil {
+                return changed, remaining, errors.New(`The value of --off-mutes needs to be true or false.`)
+            }
+            configuration.OffMutes = offMutes
+            changed = true
+        } else if strings.HasPrefix(arg, "--playpause-action=") {
+            playPauseAction, err := strconv.ParseBool(strings.TrimPrefix(arg, `--playpause-action=`))
+            if err != nil {
+                return changed, remaining, errors.New(`The value of --playpause-action needs to be true or false.`)
+            }
+            configuration.PlayPauseAction = playPauseAction
+            changed = true
+        } else if strings.HasPrefix(arg, "--volume-osd-setting=") {
+            configuration.VolumeOSDSetting = strings.TrimPrefix(arg, `--volume-osd-setting=`)
+            changed = true
+        } else if strings.HasPrefix(arg, "--kodi-version=") {
+            version := strings.TrimPrefix(arg, `--kodi-version=`)
+            if name := getArgumentValue(args, `--profile=`); len(name) > 0 {
+                if err := configuration.SetProfileKodiVersion(name, version); err != nil {
+                    return changed, remaining, err
+                }
+            } else {
+                configuration.KodiVersion = version
+            }
+            changed = true
+        } else if arg == `--config-migrate` {
+            // the configuration was migrated while loading, it only needs to be saved
+            changed = true
+        } else if strings.HasPrefix(arg, "--log-level=") {
+            level, err := logging.ParseLevel(strings.TrimPrefix(arg, `--log-level=`))
+            if err != nil {
+                return changed, remaining, err
+            }
+            logging.SetLevel(level)
+        } else if arg == `--verbose` {
+            logging.SetLevel(logging.DebugLevel)
+        } else if strings.HasPrefix(arg, "--log-format=") {
+            if err := logging.SetFormat(strings.TrimPrefix(arg, `--log-format=`)); err != nil {
+                return changed, remaining, err
+            }
+        } else if arg == `--repeat-until-success` {
+            configuration.MaxAttempts = administration.DefaultMaxAttempts
+        } else if strings.HasPrefix(arg, "--repeat-until-success=") {
+            maxAttempts, err := strconv.Atoi(strings.TrimPrefix(arg, `--repeat-until-success=`))
+            if err != nil || maxAttempts < 1 {
+                return changed, remaining, errors.New(`The value of --repeat-until-success needs to be a number greater than 0.`)
+            }
+            configuration.MaxAttempts = maxAttempts
+        } else if arg == `--yes` {
+            configuration.AssumeYes = true
+        } else if strings.HasPrefix(arg, "--output=") {
+            configuration.OutputFormat = strings.TrimPrefix(arg, `--output=`)
+            if !hasArgument(kodicommunicator.OutputFormats, configuration.OutputFormat) {
+                return changed, remaining, errors.New(`Unknown output format ` + configuration.OutputFormat + `, use ` + strings.Join(kodicommunicator.OutputFormats, `, `) + `.`)
+            }
+        } else if strings.HasPrefix(arg, "--color=") {
+            configuration.Color = strings.TrimPrefix(arg, `--color=`)
+            if !hasArgument(kodicommunicator.ColorModes, configuration.Color) {
+                return changed, remaining, errors.New(`Unknown color mode ` + configuration.Color + `, use ` + strings.Join(kodicommunicator.ColorModes, `, `) + `.`)
+            }
+        } else if arg == `--no-color` {
+            configuration.Color = kodicommunicator.ColorNever
+        } else if arg == `--quiet` {
+            configuration.Quiet = true
+        } else if strings.HasPrefix(arg, "--output-template=") {
+            configuration.OutputTemplate = strings.TrimPrefix(arg, `--output-template=`)
+        } else if strings.HasPrefix(arg, "--param-json=") {
+            if err := json.Unmarshal([]byte(strings.TrimPrefix(arg, `--param-json=`)), &configuration.RawParams); err != nil {
+                return changed, remaining, errors.New(`The value of --param-json is no valid JSON object: ` + err.Error())
+            }
+        } else if arg == `--param` || strings.HasPrefix(arg, "--param=") {
+            pair := strings.TrimPrefix(arg, `--param=`)
+            if arg == `--param` {
+                if i + 1 >= len(args) {
+                    return changed, remaining, errors.New(`--param needs a value like key=value.`)
+                }
+                i++
+                pair = args[i]
+            }
+            if err := addRawParam(configuration, pair); err != nil {
+                return changed, remaining, err
+            }
+        } else if arg == `--expect` || strings.HasPrefix(arg, "--expect=") {
+            expectation := strings.TrimPrefix(arg, `--expect=`)
+            if arg == `--expect` {
+                if i + 1 >= len(args) {
+                    return changed, remaining, errors.New(`--expect needs a value like key=value.`)
+                }
+                i++
+                expectation = args[i]
+            }
+            if !strings.Contains(expectation, `=`) || strings.HasPrefix(expectation, `=`) {
+                return changed, remaining, errors.New(`Illegal expectation ` + expectation + `, use key=value.`)
+            }
+            configuration.Expectations = append(configuration.Expectations, expectation)
+        } else if strings.HasPrefix(arg, "--params-file=") {
+            path := strings.TrimPrefix(arg, `--params-file=`)
+            content, err := ioutil.ReadFile(path)
+            if err != nil {
+                return changed, remaining, err
+            }
+            if err := json.Unmarshal(content, &configuration.RawParams); err != nil {
+                return changed, remaining, errors.New(`The file ` + path + ` contains no valid JSON object: ` + err.Error())
+            }
+        } else if arg == `--no-config-write` {
+            configuration.NoConfigWrite = true
+        } else if arg == `--show-target` || strings.HasPrefix(arg, `--profile=`) || arg == `--stdin` || arg == `--fail-fast` || strings.HasPrefix(arg, `--batch=`) {
+            // already handled by main
+        } else {
+            remaining = append(remaining, arg)
         }
     }
-    return changed
+    for _, arg := range remaining {
+        if strings.HasPrefix(arg, `--`) && arg != `--` && !kodicommunicator.IsCommandFlag(remaining, strings.Split(arg, `=`)[0]) {
+            return changed, remaining, errors.New(`unknown flag: ` + strings.Split(arg, `=`)[0])
+        }
+    }
+    return changed, remaining, nil
+}
+
+// addRawParam adds a pair like "volume=50" to the raw params. true, false
+// and numbers are sent as boolean and number.
+func addRawParam(configuration *administration.Configuration, pair string) error {
+    keyValue := strings.SplitN(pair, `=`, 2)
+    if len(keyValue) < 2 || len(keyValue[0]) == 0 {
+        return errors.New(`Illegal param ` + pair + `, use key=value.`)
+    }
+    if configuration.RawParams == nil {
+        configuration.RawParams = map[string]interface{}{}
+    }
+    configuration.RawParams[keyValue[0]] = kodicommunicator.CoerceValue(keyValue[1])
+    return nil
 }
 
 func splitParameterIntoMap(args []string) map[string]interface{} {
@@ -39,7 +215,19 @@ func splitParameterIntoMap(args []string) map[string]interface{} {
 }
 
 func printHelp(args []string) {
-    fmt.Println(`If you run the tool the first time you need to configure it. Therefore you need to call it with the parameters --host=<kodi-address> and --port=<kodi-port>.`)
+    fmt.Println(`If you run the tool the first time you need to configure it. Therefore you need to call it with the parameter --addr=<kodi-host>:<kodi-port>, the port defaults to ` + administration.DefaultPort + `. IPv6 hosts are written in brackets, e.g. --addr=[::1]:8080.`)
+    fmt.Println(`The separate parameters --host=<kodi-address> and --port=<kodi-port> are still supported.`)
+    fmt.Println(`The environment variable ` + administration.AddressVariable + `=<kodi-host>:<kodi-port> overrides the configured address without saving it.`)
+    fmt.Println(`To control several Kodi installations save their addresses as profiles with --add-profile=<name>=<host>:<port>. Pass --profile=<name> to use one for a single call or make it the default with "use <name>".`)
+    fmt.Println(`Notifications are received from Kodi's WebSocket server on port ` + administration.DefaultWebSocketPort + `, another port can be configured with --ws-port=<port>.`)
+    fmt.Println(`Configurations saved by older versions are migrated and saved automatically, call it with --config-migrate to migrate and save it explicitly.`)
+    fmt.Println(`Add --no-config-write to use the parameters for this call only without saving them, e.g. in read-only environments.`)
+    fmt.Println(`The time to connect and the time for a whole request can be limited with --timeout-connect=<duration> (default 5s) and --timeout-read=<duration> (default no limit), e.g. --timeout-read=10m.`)
+    fmt.Println(`To mute the audio whenever the off command stops playback call it with the parameter --off-mutes=true.`)
+    fmt.Println(`If play and pause have no effect on the playback of some add-ons call it with the parameter --playpause-action=true to send them as the action "playpause" like a remote control does.`)
+    fmt.Println(`To hide the volume bar while fade and volpct are called with --silent-volume, call it with --volume-osd-setting=<id> naming the boolean setting of your Kodi or skin which shows the volume bar.`)
+    fmt.Println(`The version of Kodi's JSON-RPC API is detected on the first call and saved. After upgrading Kodi call it with --kodi-version= to detect it again or with e.g. --kodi-version=9.0.0 to set it manually. Profiles have their own version, add --profile=<name> to change it.`)
+    fmt.Println(`To log every request sent to Kodi call it with the parameter --log-file=<path>, an empty path disables the log.`)
     fmt.Println()
     fmt.Println(`If the tool is properly configured you can just run it by passing the name of the command as the first parameter and as the second parameter the parameter for the command.`)
     fmt.Println(`The command-params need to be passed like "title:test123,message:I'm here!" so a complete call would look like 'krm notify "title:test123,I'm here!'`)
@@ -48,9 +236,12 @@ func printHelp(args []string) {
 
 func checkAndPrintHelp(args []string) bool {
     for idx, arg := range args {
-        if arg == `help` {
+        if arg == `help` || arg == `--help` || arg == `-h` {
             if idx < len(args) - 1 {
                 command, success := kodicommunicator.GetCommandForName(args[idx + 1])
+                if pseudoCommand, isPseudoCommand := pseudoCommands[args[idx + 1]]; isPseudoCommand {
+                    command, success = *pseudoCommand, true
+                }
                 if success {
                     fmt.Println(`Help for command`, command.CliName)
                     fmt.Println(`Description:`, command.Description)
@@ -61,7 +252,7 @@ func checkAndPrintHelp(args []string) bool {
                         }
                     }
                 } else {
-                    fmt.Println("The command", arg, "is not supported.")
+                    fmt.Println("The command", args[idx + 1], "is not supported.")
                 }
             } else {
                 printHelp(args);
@@ -75,40 +266,435 @@ func checkAndPrintHelp(args []string) bool {
 func printUsage(args []string) {
     fmt.Println(`Usage:`, args[0], `command [paramter]`)
     fmt.Println(`Parameters are entered as follows: "key1:value,key2:value"`)
-    fmt.Println(`To get help type`, args[0], `help`)
+    fmt.Println(`Single parameters can be passed with --param key=value, the flag can be repeated and true, false and numbers are sent as boolean and number`)
+    fmt.Println(`Raw JSON parameters can be passed with --param-json='{"key1":"value"}' or read from a file with --params-file=<path>`)
+    fmt.Println(`To get help type`, args[0], `help`, `or`, args[0], `--help`)
     fmt.Println(`To get help for a specific command type`, args[0], `help <command>`)
+    fmt.Println(`To read commands line by line from stdin type`, args[0], `-`, `or`, args[0], `--stdin`)
+    fmt.Println(`To read commands from a file type`, args[0], `--batch=<path>, blank lines and lines starting with # are skipped`)
+    fmt.Println(`Lines between "repeat <count> {" and "}" are executed count times`)
+    fmt.Println(`Use sleep <duration>, e.g. "sleep 2" or "sleep 500ms", between commands to wait without sending anything to Kodi`)
+    fmt.Println(`Add --expect key=value to fail unless the field of the response matches, e.g. "nowplaying --expect speed=0", nested fields are separated by dots`)
+    fmt.Println(`Add --fail-fast to stop reading commands at the first error`)
+    fmt.Println(`Add --repeat-until-success[=attempts] to send the requests of a command again until Kodi reports success (default ` + strconv.Itoa(administration.DefaultMaxAttempts) + ` attempts)`)
+    fmt.Println(`Kodi reports success for every key press sent with the Input methods, e.g. up or home, so dropped key presses are not repeated`)
+    fmt.Println(`Toggles like mute or fullscreen are never sent twice, because the second request would undo the first`)
+    fmt.Println(`Destructive commands like clean, remove, quit or reboot ask for confirmation, add --yes to skip it`)
+    fmt.Println(`Listings like browse, findsong and recent can be printed with --output=plain|table|json`)
+    fmt.Println(`Output is colored on terminals, change it with --color=auto|always|never or disable it with --no-color or the NO_COLOR environment variable`)
+    fmt.Println(`Add --quiet to hide the spinner shown during long running commands`)
+    fmt.Println(`Add --show-target to print the address of Kodi before sending commands, it is always printed with --verbose`)
+    fmt.Println(`Messages are logged to stderr, choose the detail with --log-level=error|info|debug (default info) or --verbose and the format with --log-format=text|json`)
+    fmt.Println(`To list all commands as JSON type`, args[0], `--list-commands --json`)
     fmt.Println()
     fmt.Println(`List of all available commands:`)
+    printCommandList()
+}
+
+// pseudoCommands are handled by the remote itself instead of being sent to Kodi.
+var pseudoCommands = map[string]*kodicommunicator.Command {
+    `sleep`: &kodicommunicator.Command {
+        CliName: `sleep`,
+        Description: `Waits before the next command is executed.`,
+        ParametersDescription: map[string]string {
+            `duration`: `The time to wait, e.g. "500ms". Plain numbers are seconds.`,
+        },
+    },
+    `wait`: &kodicommunicator.Command {
+        CliName: `wait`,
+        Description: `Waits before the next command is executed, like sleep.`,
+        ParametersDescription: map[string]string {
+            `duration`: `The time to wait, e.g. "500ms". Plain numbers are seconds.`,
+        },
+    },
+    `use`: &kodicommunicator.Command {
+        CliName: `use`,
+        Description: `Saves the profile used if none is passed with --profile and uses it for the following commands.`,
+        ParametersDescription: map[string]string {
+            `profile`: `The name of a profile added with --add-profile.`,
+        },
+    },
+}
+
+func printCommandList() {
     for key, value := range kodicommunicator.CommandMap {
         fmt.Println(key, `-`, value.Description)
     }
+    for key, value := range pseudoCommands {
+        fmt.Println(key, `-`, value.Description)
+    }
+}
+
+// hasArgument returns true if the passed argument is part of args.
+func hasArgument(args []string, argument string) bool {
+    for _, arg := range args {
+        if arg == argument {
+            return true
+        }
+    }
+    return false
+}
+
+// applyAddressVariable sets the address of Kodi from the environment unless
+// it was passed in args. The address in the environment is never saved.
+func applyAddressVariable(config *administration.Configuration, args []string) error {
+    address := os.Getenv(administration.AddressVariable)
+    if len(address) == 0 {
+        return nil
+    }
+    if hasAddressArgument(args) {
+        return nil
+    }
+    return config.SetAddress(address)
+}
+
+// applyAddressOverrides replaces the configured address of Kodi by the one
+// from the environment or from a profile for this call.
+func applyAddressOverrides(config *administration.Configuration, args []string) error {
+    if err := applyAddressVariable(config, args); err != nil {
+        return err
+    }
+    return applyProfile(config, args)
+}
+
+// hasAddressArgument returns true if the address of Kodi is part of args.
+func hasAddressArgument(args []string) bool {
+    for _, arg := range args {
+        if strings.HasPrefix(arg, `--addr=`) || strings.HasPrefix(arg, `--host=`) || strings.HasPrefix(arg, `--port=`) {
+            return true
+        }
+    }
+    return false
+}
+
+// applyProfile sets the address of Kodi from the profile passed with
+// --profile= or from the default profile. The default profile is not used
+// if an address was passed in args or in the environment.
+func applyProfile(config *administration.Configuration, args []string) error {
+    name := getArgumentValue(args, `--profile=`)
+    if len(name) == 0 {
+        if len(os.Getenv(administration.AddressVariable)) > 0 || hasAddressArgument(args) {
+            return nil
+        }
+        name = config.DefaultProfile
+    }
+    if len(name) == 0 {
+        return nil
+    }
+    return config.UseProfile(name)
+}
+
+// useProfile saves the profile in params as the default profile and
+// switches config to it for the following commands. The address of the
+// profile is not saved as host and port.
+func useProfile(config *administration.Configuration, params []string) error {
+    if len(params) < 1 {
+        return errors.New(`Not enough parameters. See "help use" for usage information.`)
+    }
+    if config.NoConfigWrite {
+        return errors.New(`The default profile can't be saved with --no-config-write, pass --profile=` + params[0] + ` instead.`)
+    }
+    if err := config.UseProfile(params[0]); err != nil {
+        return err
+    }
+    kodicommunicator.InvalidatePlayerIDCache()
+    saved := administration.ReadConfiguration()
+    saved.DefaultProfile = params[0]
+    if err := administration.WriteConfiguration(saved); err != nil {
+        return err
+    }
+    printTarget(*config, hasArgument(os.Args, `--show-target`))
+    detectKodiVersion(config, false)
+    return nil
+}
+
+func checkAndListCommands(args []string) bool {
+    listCommands, asJson := false, false
+    for _, arg := range args {
+        if arg == `--list-commands` {
+            listCommands = true
+        } else if arg == `--json` {
+            asJson = true
+        }
+    }
+    if listCommands {
+        if asJson {
+            commands := map[string]*kodicommunicator.Command{}
+            for name, command := range kodicommunicator.CommandMap {
+                commands[name] = command
+            }
+            for name, command := range pseudoCommands {
+                commands[name] = command
+            }
+            if output, err := json.MarshalIndent(commands, ``, `    `); err == nil {
+                fmt.Println(string(output))
+            } else {
+                fmt.Println(err.Error())
+            }
+        } else {
+            printCommandList()
+        }
+    }
+    return listCommands
+}
+
+// executeArguments executes the command given by args and prints its output.
+// The pseudo-commands are handled without sending them to Kodi, use switches
+// config to the profile for the following commands.
+func executeArguments(config *administration.Configuration, args []string) error {
+    switch args[0] {
+    case `sleep`, `wait`:
+        return sleep(args[1:])
+    case `use`:
+        return useProfile(config, args[1:])
+    }
+    output, err := kodicommunicator.ExecuteCommand(*config, args[0], args[1:])
+    if err == nil && len(output) > 0 {
+        fmt.Println(output)
+    }
+    return err
+}
+
+// sleep pauses for the duration in params, e.g. "500ms" or "2" for two seconds.
+func sleep(params []string) error {
+    if len(params) < 1 {
+        return errors.New(`Not enough parameters. Use e.g. "sleep 2" or "sleep 500ms".`)
+    }
+    duration, err := time.ParseDuration(params[0])
+    if err != nil {
+        seconds, err := strconv.ParseFloat(params[0], 64)
+        if err != nil {
+            return errors.New(`Illegal duration ` + params[0] + `, use e.g. "2" or "500ms".`)
+        }
+        duration = time.Duration(seconds * float64(time.Second))
+    }
+    if duration < 0 {
+        return errors.New(`Illegal duration ` + params[0] + `, use e.g. "2" or "500ms".`)
+    }
+    time.Sleep(duration)
+    return nil
+}
+
+// batchLine is a command read by executeLines together with its line number.
+type batchLine struct {
+    number int
+    args []string
+}
+
+// executeLines executes every line read from reader as a command. Blank lines
+// and comments starting with # are skipped, lines between "repeat <count> {"
+// and "}" are executed count times. Errors are reported together with their
+// line number. If failFast is set the first error stops the execution,
+// otherwise the failures are summarized at the end.
+func executeLines(config *administration.Configuration, reader io.Reader, failFast bool) error {
+    scanner := bufio.NewScanner(reader)
+    lineNumber := 0
+    next := func() (batchLine, bool) {
+        for scanner.Scan() {
+            lineNumber++
+            args := strings.Fields(scanner.Text())
+            if len(args) > 0 && !strings.HasPrefix(args[0], `#`) {
+                return batchLine{lineNumber, args}, true
+            }
+        }
+        return batchLine{}, false
+    }
+    failures := []string{}
+    if err := executeBlock(config, next, failFast, &failures); err != nil {
+        return err
+    }
+    if err := scanner.Err(); err != nil {
+        return err
+    }
+    if len(failures) > 0 {
+        return errors.New(strconv.Itoa(len(failures)) + ` command(s) failed in line(s) ` + strings.Join(failures, `, `))
+    }
+    return nil
+}
+
+// executeBlock executes the lines returned by next until there are none left.
+// The numbers of the failed lines are added to failures.
+func executeBlock(config *administration.Configuration, next func() (batchLine, bool), failFast bool, failures *[]string) error {
+    for line, success := next(); success; line, success = next() {
+        var err error
+        if line.args[0] == `repeat` {
+            var count int
+            var body []batchLine
+            if count, body, err = readRepeatBlock(line, next); err == nil {
+                for i := 0; i < count; i++ {
+                    if err := executeBlock(config, iterateLines(body), failFast, failures); err != nil {
+                        return err
+                    }
+                }
+                continue
+            }
+        } else if line.args[0] == `}` {
+            err = errors.New(`Found "}" without "repeat <count> {".`)
+        } else {
+            err = executeArguments(config, line.args)
+        }
+        if err != nil {
+            if failFast {
+                return errors.New(`Line ` + strconv.Itoa(line.number) + ` - ` + err.Error())
+            }
+            logging.Error(`Line`, line.number, `-`, err.Error())
+            if !hasArgument(*failures, strconv.Itoa(line.number)) {
+                *failures = append(*failures, strconv.Itoa(line.number))
+            }
+        }
+    }
+    return nil
+}
+
+// readRepeatBlock reads the lines returned by next up to the "}" closing the
+// block started by the line "repeat <count> {" and returns the count and the
+// lines inside the block. Nested blocks are part of the returned lines.
+func readRepeatBlock(line batchLine, next func() (batchLine, bool)) (int, []batchLine, error) {
+    if line.args[len(line.args) - 1] != `{` {
+        return 0, nil, errors.New(`Illegal repeat block. Use "repeat <count> {" and close it with "}".`)
+    }
+    body := []batchLine{}
+    depth := 1
+    for inner, success := next(); success; inner, success = next() {
+        if inner.args[0] == `repeat` && inner.args[len(inner.args) - 1] == `{` {
+            depth++
+        } else if len(inner.args) == 1 && inner.args[0] == `}` {
+            depth--
+        }
+        if depth == 0 {
+            count, err := strconv.Atoi(line.args[1])
+            if len(line.args) != 3 || err != nil || count < 0 {
+                return 0, nil, errors.New(`Illegal repeat count. Use "repeat <count> {" with a count of at least 0.`)
+            }
+            return count, body, nil
+        }
+        body = append(body, inner)
+    }
+    return 0, nil, errors.New(`The repeat block is not closed with "}".`)
+}
+
+// iterateLines returns a function returning one line after another.
+func iterateLines(lines []batchLine) func() (batchLine, bool) {
+    index := 0
+    return func() (batchLine, bool) {
+        if index >= len(lines) {
+            return batchLine{}, false
+        }
+        index++
+        return lines[index - 1], true
+    }
+}
+
+// getArgumentValue returns the value of the first argument starting with prefix,
+// e.g. "cmds.txt" for "--batch=cmds.txt", or an empty string if there is none.
+func getArgumentValue(args []string, prefix string) string {
+    for _, arg := range args {
+        if strings.HasPrefix(arg, prefix) {
+            return strings.TrimPrefix(arg, prefix)
+        }
+    }
+    return ``
+}
+
+// executeFile executes every line of the file at path as a command.
+func executeFile(config *administration.Configuration, path string, failFast bool) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    return executeLines(config, file, failFast)
+}
+
+// printTarget logs the address of Kodi the commands are sent to. It is only
+// shown with --verbose unless show is set.
+func printTarget(config administration.Configuration, show bool) {
+    target := `Targeting ` + net.JoinHostPort(config.Host, config.Port)
+    if len(config.ActiveProfile) > 0 {
+        target = `Targeting ` + config.ActiveProfile + ` (` + net.JoinHostPort(config.Host, config.Port) + `)`
+    }
+    if show {
+        logging.Info(target)
+    } else {
+        logging.Debug(target)
+    }
+}
+
+// detectKodiVersion asks Kodi for its API version if none is configured yet
+// and saves it, in the active profile if there is one, unless noConfigWrite
+// is set. If the version can't be detected the commands are sent in the
+// format of the latest version.
+func detectKodiVersion(config *administration.Configuration, noConfigWrite bool) {
+    if len(config.KodiVersion) > 0 {
+        return
+    }
+    version, err := kodicommunicator.DetectKodiVersion(*config)
+    if err != nil {
+        logging.Debug(`Detecting the version of Kodi failed:`, err.Error())
+        return
+    }
+    config.KodiVersion = version
+    if !noConfigWrite {
+        if err := administration.SaveKodiVersion(*config); err != nil {
+            logging.Error(err.Error())
+        }
+    }
 }
 
 func main() {
     if len(os.Args) < 2 {
         printUsage(os.Args)
-    } else if !checkAndPrintHelp(os.Args) {
-        args := os.Args[1:]
-        config, err := administration.CreateConfiguration()
-        
+    } else if !checkAndPrintHelp(os.Args) && !checkAndListCommands(os.Args) {
+        var config administration.Configuration
+        var err error
+        noConfigWrite := hasArgument(os.Args, `--no-config-write`)
+        readStdin := hasArgument(os.Args, `--stdin`)
+        batchFile := getArgumentValue(os.Args, `--batch=`)
+        if noConfigWrite {
+            config = administration.ReadConfiguration()
+        } else {
+            config, err = administration.CreateConfiguration()
+        }
         
         if err == nil {
-            if checkAndHandleArgumentsConfig(&config, args) {
+            var changed bool
+            var args []string
+            if changed, args, err = checkAndHandleArgumentsConfig(&config, os.Args[1:]); err != nil {
+                logging.Error(err.Error())
+                os.Exit(1)
+            } else if changed && !noConfigWrite {
                 if err := administration.WriteConfiguration(config); err != nil {
-                    fmt.Println(err.Error())
+                    logging.Error(err.Error())
+                    os.Exit(1)
                 }
+            } else if len(args) == 0 && !readStdin && len(batchFile) == 0 {
+                printUsage(os.Args)
             } else {
-                if len(config.Host) == 0 {
-                    err = errors.New(`No host configured. Please see "help" to learn about how to configure the remote.`)
-                } else {
-                    err = kodicommunicator.ExecuteCommand(config, args[0], args[1:])
+                if len(batchFile) == 0 && !readStdin && pseudoCommands[args[0]] != nil {
+                    err = executeArguments(&config, args)
+                } else if err = applyAddressOverrides(&config, os.Args); err == nil {
+                    if len(config.Host) == 0 {
+                        err = errors.New(`No host configured. Please see "help" to learn about how to configure the remote.`)
+                    } else {
+                        printTarget(config, hasArgument(os.Args, `--show-target`))
+                        detectKodiVersion(&config, noConfigWrite || len(config.ActiveProfile) == 0 && len(os.Getenv(administration.AddressVariable)) > 0)
+                        if len(batchFile) > 0 {
+                            err = executeFile(&config, batchFile, hasArgument(os.Args, `--fail-fast`))
+                        } else if readStdin || args[0] == `-` {
+                            err = executeLines(&config, os.Stdin, hasArgument(os.Args, `--fail-fast`))
+                        } else {
+                            err = executeArguments(&config, args)
+                        }
+                    }
                 }
                 if err != nil {   
-                    fmt.Println(err.Error())
+                    logging.Error(err.Error())
+                    os.Exit(1)
                 }
             }
         } else {
-            fmt.Println(err.Error())
+            logging.Error(err.Error())
+            os.Exit(1)
         }
     }
 }